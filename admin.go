@@ -1,6 +1,9 @@
 package cognito
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
 )
@@ -60,14 +63,17 @@ func (c *AppClient) GetUserGroups(username string) ([]*cognitoidentityprovider.G
 		return nil, err
 	}
 
-	// Get the groups
-	out, err := cip.AdminListGroupsForUser(input)
-
+	// Get the groups, across every page
+	var groups []*cognitoidentityprovider.GroupType
+	err = cip.AdminListGroupsForUserPages(input, func(out *cognitoidentityprovider.AdminListGroupsForUserOutput, last bool) bool {
+		groups = append(groups, out.Groups...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return out.Groups, err
+	return groups, nil
 }
 
 func (c *AppClient) InGroup(groupType []*cognitoidentityprovider.GroupType, group string) bool {
@@ -122,47 +128,138 @@ func (c *AppClient) SetUserPassword(username, password string, permanent bool) e
 	return nil
 }
 
-// RegisterNewUserEmailPass creates a new user in cognito based on a username (email) and password
-// If password is null, then cognito will create the temporary password for you.
-// Requires a AWS session with developer credentials
-func (c *AppClient) RegisterNewUserEmailPass(username, password string) (cognitoID string, err error) {
+// Values for CreateUserOptions.DesiredDeliveryMediums
+const (
+	DeliveryMediumEmail = cognitoidentityprovider.DeliveryMediumTypeEmail
+	DeliveryMediumSMS   = cognitoidentityprovider.DeliveryMediumTypeSms
+)
+
+// Values for CreateUserOptions.MessageAction
+const (
+	MessageActionSuppress = cognitoidentityprovider.MessageActionTypeSuppress
+	MessageActionResend   = cognitoidentityprovider.MessageActionTypeResend
+)
 
-	var input *cognitoidentityprovider.AdminCreateUserInput
+// CreateUserOptions defines the user to create with CreateUser
+type CreateUserOptions struct {
+	// Username is required, it may be an email or phone number if the pool uses them as usernames
+	Username string
+	// TemporaryPassword is generated by Cognito if empty
+	TemporaryPassword string
+	// Email and PhoneNumber are shortcuts for the email and phone_number attributes
+	Email       string
+	PhoneNumber string
+	// EmailVerified and PhoneNumberVerified mark the shortcut attributes as verified
+	EmailVerified       bool
+	PhoneNumberVerified bool
+	// Attributes are any other user attributes, custom attributes are prefixed with "custom:"
+	Attributes map[string]string
+	// DesiredDeliveryMediums is EMAIL and/or SMS, defaults to SMS in Cognito
+	DesiredDeliveryMediums []string
+	// MessageAction is SUPPRESS to not send the invitation or RESEND to resend it for an existing user
+	MessageAction string
+	// ClientMetadata is passed to the pre sign-up, custom message and post confirmation triggers
+	ClientMetadata map[string]string
+	// ValidationData is passed to the pre sign-up trigger
+	ValidationData map[string]string
+	// ForceAliasCreation migrates an email or phone alias from an existing user
+	ForceAliasCreation bool
+}
 
-	emailAt := &cognitoidentityprovider.AttributeType{
-		Name:  aws.String("email"),
-		Value: aws.String(username),
+// createUserInput builds the AdminCreateUserInput for the options
+func (c *AppClient) createUserInput(opts *CreateUserOptions) (*cognitoidentityprovider.AdminCreateUserInput, error) {
+	if opts.Username == "" {
+		return nil, errors.New("username is required")
 	}
-	emailVerifiedAt := &cognitoidentityprovider.AttributeType{
-		Name:  aws.String("email_verified"),
-		Value: aws.String("true"),
+
+	attributes := make(map[string]string, len(opts.Attributes)+4)
+	for name, value := range opts.Attributes {
+		attributes[name] = value
+	}
+	if opts.Email != "" {
+		attributes["email"] = opts.Email
+		if opts.EmailVerified {
+			attributes["email_verified"] = "true"
+		}
+	}
+	if opts.PhoneNumber != "" {
+		attributes["phone_number"] = opts.PhoneNumber
+		if opts.PhoneNumberVerified {
+			attributes["phone_number_verified"] = "true"
+		}
 	}
 
-	if password != "" {
-		input = &cognitoidentityprovider.AdminCreateUserInput{
-			Username:          aws.String(username),
-			TemporaryPassword: aws.String(password),
-			UserPoolId:        &c.UserPoolID,
-			UserAttributes:    []*cognitoidentityprovider.AttributeType{emailAt, emailVerifiedAt},
+	input := &cognitoidentityprovider.AdminCreateUserInput{
+		Username:   aws.String(opts.Username),
+		UserPoolId: &c.UserPoolID,
+	}
+	if len(attributes) > 0 {
+		input.UserAttributes = attributeTypes(attributes)
+	}
+	if opts.TemporaryPassword != "" {
+		input.TemporaryPassword = aws.String(opts.TemporaryPassword)
+	}
+	for _, medium := range opts.DesiredDeliveryMediums {
+		if medium != DeliveryMediumEmail && medium != DeliveryMediumSMS {
+			return nil, fmt.Errorf("invalid delivery medium %q", medium)
 		}
-	} else {
-		input = &cognitoidentityprovider.AdminCreateUserInput{
-			Username:       aws.String(username),
-			UserPoolId:     &c.UserPoolID,
-			UserAttributes: []*cognitoidentityprovider.AttributeType{emailAt, emailVerifiedAt},
+		input.DesiredDeliveryMediums = append(input.DesiredDeliveryMediums, aws.String(medium))
+	}
+	if opts.MessageAction != "" {
+		if opts.MessageAction != MessageActionSuppress && opts.MessageAction != MessageActionResend {
+			return nil, fmt.Errorf("invalid message action %q", opts.MessageAction)
 		}
+		input.MessageAction = aws.String(opts.MessageAction)
+	}
+	if len(opts.ClientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(opts.ClientMetadata)
+	}
+	if len(opts.ValidationData) > 0 {
+		// Validation data goes to the pre sign-up trigger as is, like the client metadata
+		input.ValidationData = nameValues(opts.ValidationData, nil)
+	}
+	if opts.ForceAliasCreation {
+		input.ForceAliasCreation = aws.Bool(true)
+	}
+
+	return input, nil
+}
+
+// CreateUser creates a new user in cognito as an administrator and returns the created user
+// Requires a AWS session with developer credentials
+func (c *AppClient) CreateUser(opts *CreateUserOptions) (*User, error) {
+	input, err := c.createUserInput(opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the CognitoIdentityProvider
 	cip, err := c.NewCIP()
 	if err != nil {
-		return
+		return nil, err
 	}
 	out, err := cip.AdminCreateUser(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUser(out.User), nil
+}
+
+// RegisterNewUserEmailPass creates a new user in cognito based on a username (email) and password
+// If password is null, then cognito will create the temporary password for you.
+// Requires a AWS session with developer credentials
+func (c *AppClient) RegisterNewUserEmailPass(username, password string) (cognitoID string, err error) {
+	user, err := c.CreateUser(&CreateUserOptions{
+		Username:          username,
+		TemporaryPassword: password,
+		Email:             username,
+		EmailVerified:     true,
+	})
 	if err != nil {
 		return
 	}
-	cognitoID = *out.User.Username
+	cognitoID = user.Username
 
 	return
 }
@@ -184,3 +281,91 @@ func (c *AppClient) DeleteUser(username string) error {
 	return err
 
 }
+
+// AdminDisableUser disables a user so they can no longer sign in, without deleting
+// their data. If signOut is true all of the user's refresh tokens are revoked as well.
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminDisableUser(username string, signOut bool) error {
+	input := &cognitoidentityprovider.AdminDisableUserInput{
+		Username:   aws.String(username),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+	_, err = cip.AdminDisableUser(input)
+	if err != nil {
+		return err
+	}
+
+	if signOut {
+		_, err = cip.AdminUserGlobalSignOut(&cognitoidentityprovider.AdminUserGlobalSignOutInput{
+			Username:   aws.String(username),
+			UserPoolId: &c.UserPoolID,
+		})
+	}
+
+	return err
+}
+
+// AdminEnableUser re-enables a disabled user
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminEnableUser(username string) error {
+	input := &cognitoidentityprovider.AdminEnableUserInput{
+		Username:   aws.String(username),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+	_, err = cip.AdminEnableUser(input)
+
+	return err
+}
+
+// AdminUserGlobalSignOut revokes all refresh tokens issued to a user
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminUserGlobalSignOut(username string) error {
+	input := &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		Username:   aws.String(username),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+	_, err = cip.AdminUserGlobalSignOut(input)
+
+	return err
+}
+
+// AdminResetUserPassword invalidates a user's password and sends them a reset code,
+// the user's status becomes RESET_REQUIRED until they set a new password.
+// clientMetadata is passed to the custom message trigger and may be nil.
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminResetUserPassword(username string, clientMetadata map[string]string) error {
+	input := &cognitoidentityprovider.AdminResetUserPasswordInput{
+		Username:   aws.String(username),
+		UserPoolId: &c.UserPoolID,
+	}
+	if len(clientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(clientMetadata)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+	_, err = cip.AdminResetUserPassword(input)
+
+	return err
+}
@@ -0,0 +1,193 @@
+package cognito
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// Admin authentication flows, ADMIN_NO_SRP_AUTH is the legacy name of ADMIN_USER_PASSWORD_AUTH
+const (
+	AuthFlowAdminUserPassword = cognitoidentityprovider.AuthFlowTypeAdminUserPasswordAuth
+	AuthFlowAdminNoSRP        = cognitoidentityprovider.AuthFlowTypeAdminNoSrpAuth
+)
+
+// AuthContext describes the end user's request for Cognito advanced security
+type AuthContext struct {
+	IPAddress  string
+	ServerName string
+	ServerPath string
+	Headers    map[string]string
+	// EncodedData is the device fingerprint collected by the Cognito JS or mobile SDK
+	EncodedData string
+}
+
+// AuthContextHeaders are the request headers NewAuthContext passes to Cognito. Credentials
+// such as Cookie and Authorization are left out on purpose, they must not leave the app.
+var AuthContextHeaders = []string{
+	"User-Agent",
+	"Accept",
+	"Accept-Language",
+	"Accept-Encoding",
+	"Referer",
+	"Origin",
+}
+
+// NewAuthContext builds an AuthContext from the request of the end user, with the headers
+// in AuthContextHeaders. X-Forwarded-For is not trusted, set IPAddress yourself behind a proxy.
+func NewAuthContext(r *http.Request) *AuthContext {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	headers := map[string]string{}
+	for _, name := range AuthContextHeaders {
+		if value := r.Header.Get(name); value != "" {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+
+	return &AuthContext{
+		IPAddress:  ip,
+		ServerName: r.Host,
+		ServerPath: r.URL.Path,
+		Headers:    headers,
+	}
+}
+
+// contextData converts the AuthContext to the Cognito type, headers sorted by name
+func (ac *AuthContext) contextData() *cognitoidentityprovider.ContextDataType {
+	if ac == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(ac.Headers))
+	for name := range ac.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := make([]*cognitoidentityprovider.HttpHeader, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, &cognitoidentityprovider.HttpHeader{
+			HeaderName:  aws.String(name),
+			HeaderValue: aws.String(ac.Headers[name]),
+		})
+	}
+
+	cd := &cognitoidentityprovider.ContextDataType{
+		IpAddress:   aws.String(ac.IPAddress),
+		ServerName:  aws.String(ac.ServerName),
+		ServerPath:  aws.String(ac.ServerPath),
+		HttpHeaders: headers,
+	}
+	if ac.EncodedData != "" {
+		cd.EncodedData = aws.String(ac.EncodedData)
+	}
+	return cd
+}
+
+// AdminAuthOptions defines a server side authentication with AdminInitiateAuth
+type AdminAuthOptions struct {
+	Username string
+	Password string
+	// AuthFlow defaults to ADMIN_USER_PASSWORD_AUTH
+	AuthFlow string
+	// ClientMetadata is passed to the Lambda triggers
+	ClientMetadata map[string]string
+	// Context is the end user's request, for advanced security
+	Context *AuthContext
+}
+
+// AdminAuthenticateUserPassword authenticates a user server side with AdminInitiateAuth.
+// The result holds either the tokens or a challenge to answer with AdminRespondToAuthChallenge.
+// Remembered devices are not used by admin authentication.
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminAuthenticateUserPassword(opts *AdminAuthOptions) (*AuthResult, error) {
+	if opts.Username == "" {
+		return nil, errors.New("username is required")
+	}
+	flow := opts.AuthFlow
+	if flow == "" {
+		flow = AuthFlowAdminUserPassword
+	}
+	if flow != AuthFlowAdminUserPassword && flow != AuthFlowAdminNoSRP {
+		return nil, errors.New("unsupported admin auth flow " + flow)
+	}
+
+	params := map[string]string{
+		"USERNAME": opts.Username,
+		"PASSWORD": opts.Password,
+	}
+	c.addSecretHash(params, opts.Username)
+
+	input := &cognitoidentityprovider.AdminInitiateAuthInput{
+		AuthFlow:       aws.String(flow),
+		AuthParameters: aws.StringMap(params),
+		ClientId:       aws.String(c.ClientID),
+		UserPoolId:     &c.UserPoolID,
+		ContextData:    opts.Context.contextData(),
+	}
+	if len(opts.ClientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(opts.ClientMetadata)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.AdminInitiateAuth(input)
+	if err != nil {
+		return nil, err
+	}
+	prev := &AuthResult{Username: opts.Username, ClientMetadata: opts.ClientMetadata, loginName: opts.Username}
+	return newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session), nil
+}
+
+// AdminRespondToAuthChallenge answers the challenge in prev server side, e.g. NEW_PASSWORD_REQUIRED
+// with {"NEW_PASSWORD": password}. USERNAME and SECRET_HASH are added to the responses.
+// ctx is the end user's request for advanced security and may be nil.
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminRespondToAuthChallenge(prev *AuthResult, responses map[string]string, ctx *AuthContext) (*AuthResult, error) {
+	if prev == nil || prev.ChallengeName == "" {
+		return nil, errors.New("no challenge to respond to")
+	}
+
+	all := map[string]string{"USERNAME": prev.Username}
+	c.addSecretHash(all, prev.Username)
+	for k, v := range responses {
+		all[k] = v
+	}
+
+	input := &cognitoidentityprovider.AdminRespondToAuthChallengeInput{
+		ChallengeName:      aws.String(prev.ChallengeName),
+		ChallengeResponses: aws.StringMap(all),
+		ClientId:           aws.String(c.ClientID),
+		UserPoolId:         &c.UserPoolID,
+		ContextData:        ctx.contextData(),
+	}
+	if prev.Session != "" {
+		input.Session = aws.String(prev.Session)
+	}
+	if len(prev.ClientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(prev.ClientMetadata)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.AdminRespondToAuthChallenge(input)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session), nil
+}
@@ -0,0 +1,42 @@
+package cognito
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthContext(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://app.example.com/login", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("Accept-Language", "en-US")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Proxy-Authorization", "Basic secret")
+	r.Header.Set("X-Custom", "custom")
+
+	ac := NewAuthContext(r)
+	assert.Equal(t, "203.0.113.7", ac.IPAddress)
+	assert.Equal(t, "app.example.com", ac.ServerName)
+	assert.Equal(t, "/login", ac.ServerPath)
+	assert.Equal(t, map[string]string{"User-Agent": "test-agent", "Accept-Language": "en-US"}, ac.Headers)
+
+	cd := ac.contextData()
+	assert.Equal(t, "203.0.113.7", aws.StringValue(cd.IpAddress))
+	assert.Len(t, cd.HttpHeaders, 2)
+	assert.Equal(t, "Accept-Language", aws.StringValue(cd.HttpHeaders[0].HeaderName))
+	assert.Equal(t, "User-Agent", aws.StringValue(cd.HttpHeaders[1].HeaderName))
+	assert.Nil(t, cd.EncodedData)
+
+	var nilContext *AuthContext
+	assert.Nil(t, nilContext.contextData())
+}
+
+func TestAdminAuthenticateUserPasswordInvalidFlow(t *testing.T) {
+	c := &AppClient{}
+	_, err := c.AdminAuthenticateUserPassword(&AdminAuthOptions{Username: "jdoe", AuthFlow: "USER_SRP_AUTH"})
+	assert.NotNil(t, err)
+}
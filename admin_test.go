@@ -0,0 +1,78 @@
+package cognito
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUserInput(t *testing.T) {
+	c := &AppClient{UserPoolID: "us-east-1_abc"}
+
+	input, err := c.createUserInput(&CreateUserOptions{
+		Username:               "jdoe",
+		Email:                  "jdoe@example.com",
+		EmailVerified:          true,
+		PhoneNumber:            "+15555550100",
+		Attributes:             map[string]string{"tenant": "acme"},
+		DesiredDeliveryMediums: []string{DeliveryMediumEmail, DeliveryMediumSMS},
+		MessageAction:          MessageActionSuppress,
+		ClientMetadata:         map[string]string{"source": "import"},
+		ForceAliasCreation:     true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "jdoe", aws.StringValue(input.Username))
+	assert.Nil(t, input.TemporaryPassword)
+	assert.Equal(t, map[string]string{
+		"custom:tenant":  "acme",
+		"email":          "jdoe@example.com",
+		"email_verified": "true",
+		"phone_number":   "+15555550100",
+	}, attributeMap(input.UserAttributes))
+	assert.Equal(t, []string{"EMAIL", "SMS"}, aws.StringValueSlice(input.DesiredDeliveryMediums))
+	assert.Equal(t, "SUPPRESS", aws.StringValue(input.MessageAction))
+	assert.Equal(t, "import", aws.StringValue(input.ClientMetadata["source"]))
+	assert.True(t, aws.BoolValue(input.ForceAliasCreation))
+}
+
+func TestCreateUserInputInvalid(t *testing.T) {
+	c := &AppClient{UserPoolID: "us-east-1_abc"}
+
+	_, err := c.createUserInput(&CreateUserOptions{})
+	assert.NotNil(t, err, "Missing username accepted")
+
+	_, err = c.createUserInput(&CreateUserOptions{Username: "jdoe", MessageAction: "SEND"})
+	assert.NotNil(t, err, "Invalid message action accepted")
+
+	_, err = c.createUserInput(&CreateUserOptions{Username: "jdoe", DesiredDeliveryMediums: []string{"PIGEON"}})
+	assert.NotNil(t, err, "Invalid delivery medium accepted")
+}
+
+func TestCreateUserValidationData(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	c := srv.client()
+
+	srv.handle("AdminCreateUser", func(in map[string]interface{}) (interface{}, string) {
+		return map[string]interface{}{"User": map[string]interface{}{"Username": in["Username"]}}, ""
+	})
+
+	_, err := c.CreateUser(&CreateUserOptions{
+		Username:       "jdoe",
+		Attributes:     map[string]string{"tenant": "acme"},
+		ValidationData: map[string]string{"tenant": "acme", "invite": "abc123"},
+	})
+	assert.Nil(t, err)
+
+	if assert.Len(t, srv.calls, 1) {
+		in := srv.calls[0].Input
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"Name": "custom:tenant", "Value": "acme"},
+		}, in["UserAttributes"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"Name": "invite", "Value": "abc123"},
+			map[string]interface{}{"Name": "tenant", "Value": "acme"},
+		}, in["ValidationData"])
+	}
+}
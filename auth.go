@@ -0,0 +1,287 @@
+package cognito
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// AuthResult is the outcome of an authentication step, either the tokens of
+// the signed in user or the next challenge to answer with RespondToAuthChallenge
+type AuthResult struct {
+	// Token is set once authentication succeeded
+	Token *Token
+	// IDClaims are the verified claims of the ID token, set by AuthenticatePassword
+	IDClaims *IDClaims
+	// ChallengeName is the next challenge, e.g. SOFTWARE_TOKEN_MFA, if authentication is not complete
+	ChallengeName string
+	// ChallengeParameters are the parameters of the challenge
+	ChallengeParameters map[string]string
+	// Session must be returned with the challenge response
+	Session string
+	// Username is the username Cognito identifies the user by in challenges
+	Username string
+	// NewDevice is set when Cognito tracks the device for the first time,
+	// it is confirmed automatically if the app client has a DeviceStore
+	NewDevice *DeviceMetadata
+	// DeviceError is set if the new device could not be confirmed, authentication
+	// succeeded anyway and the device is not remembered
+	DeviceError error
+	// ClientMetadata is passed to the Lambda triggers with every challenge response
+	ClientMetadata map[string]string
+
+	// loginName is the username the authentication was started with,
+	// remembered devices are stored under it
+	loginName string
+}
+
+// Authenticated reports whether authentication is complete
+func (r *AuthResult) Authenticated() bool {
+	return r.Token != nil
+}
+
+// newAuthResult builds an AuthResult from the fields shared by all Cognito authentication outputs
+func newAuthResult(prev *AuthResult, res *cognitoidentityprovider.AuthenticationResultType, name *string, params map[string]*string, session *string) *AuthResult {
+	r := &AuthResult{
+		ChallengeName:       aws.StringValue(name),
+		ChallengeParameters: aws.StringValueMap(params),
+		Session:             aws.StringValue(session),
+		Username:            prev.Username,
+		ClientMetadata:      prev.ClientMetadata,
+		loginName:           prev.loginName,
+	}
+	if u, ok := r.ChallengeParameters["USERNAME"]; ok && u != "" {
+		r.Username = u
+	}
+	if res != nil {
+		r.Token = &Token{
+			IDToken:      aws.StringValue(res.IdToken),
+			AccessToken:  aws.StringValue(res.AccessToken),
+			RefreshToken: aws.StringValue(res.RefreshToken),
+			ExpiresIn:    int(aws.Int64Value(res.ExpiresIn)),
+			TokenType:    aws.StringValue(res.TokenType),
+		}
+		if md := res.NewDeviceMetadata; md != nil {
+			r.NewDevice = &DeviceMetadata{
+				DeviceKey:      aws.StringValue(md.DeviceKey),
+				DeviceGroupKey: aws.StringValue(md.DeviceGroupKey),
+			}
+		}
+	}
+	return r
+}
+
+// SecretHash returns the SECRET_HASH for username, Base64(HMAC_SHA256(client_secret, username + client_id)),
+// or an empty string if the app client has no secret
+func (c *AppClient) SecretHash(username string) string {
+	if c.ClientSecret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(c.ClientSecret))
+	mac.Write([]byte(username + c.ClientID))
+	return b64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// addSecretHash sets SECRET_HASH in params if the app client has a secret
+func (c *AppClient) addSecretHash(params map[string]string, username string) {
+	if h := c.SecretHash(username); h != "" {
+		params["SECRET_HASH"] = h
+	}
+}
+
+// AuthenticateSRP authenticates a user with USER_SRP_AUTH, so the password never leaves the client.
+// If the app client has a DeviceStore, remembered devices are used to skip MFA and new devices
+// are confirmed and saved.
+// The result holds either the tokens or a challenge, e.g. SOFTWARE_TOKEN_MFA, to answer with
+// RespondToAuthChallenge.
+func (c *AppClient) AuthenticateSRP(credentials *Credentials) (*AuthResult, error) {
+	srp, err := newSRPClient(srpPoolName(c.UserPoolID))
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"USERNAME": credentials.Username,
+		"SRP_A":    srp.SRPA(),
+	}
+	c.addSecretHash(params, credentials.Username)
+	device, err := c.rememberedDevice(credentials.Username)
+	if err != nil {
+		return nil, err
+	}
+	if device != nil {
+		params["DEVICE_KEY"] = device.DeviceKey
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.InitiateAuth(&cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow:       aws.String(cognitoidentityprovider.AuthFlowTypeUserSrpAuth),
+		AuthParameters: aws.StringMap(params),
+		ClientId:       aws.String(c.ClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	prev := &AuthResult{Username: credentials.Username, loginName: credentials.Username}
+	r := newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session)
+
+	if r.ChallengeName == cognitoidentityprovider.ChallengeNameTypePasswordVerifier {
+		r, err = c.passwordVerifier(cip, srp, r, credentials.Password, device)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.finishAuth(cip, r)
+}
+
+// passwordVerifier answers the PASSWORD_VERIFIER challenge of an SRP authentication
+func (c *AppClient) passwordVerifier(cip *cognitoidentityprovider.CognitoIdentityProvider, srp *srpClient, r *AuthResult, password string, device *DeviceSecrets) (*AuthResult, error) {
+	p := r.ChallengeParameters
+	userID := p["USER_ID_FOR_SRP"]
+	timestamp, signature, err := srp.passwordClaim(userID, password, p["SRP_B"], p["SALT"], p["SECRET_BLOCK"], time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	responses := map[string]string{
+		"USERNAME":                    userID,
+		"PASSWORD_CLAIM_SECRET_BLOCK": p["SECRET_BLOCK"],
+		"PASSWORD_CLAIM_SIGNATURE":    signature,
+		"TIMESTAMP":                   timestamp,
+	}
+	c.addSecretHash(responses, r.Username)
+	if device != nil {
+		responses["DEVICE_KEY"] = device.DeviceKey
+	}
+
+	return c.respondToAuthChallenge(cip, r, responses)
+}
+
+// RespondToAuthChallenge answers the challenge in prev, e.g. SOFTWARE_TOKEN_MFA with
+// {"SOFTWARE_TOKEN_MFA_CODE": code}. USERNAME, SECRET_HASH and DEVICE_KEY are added
+// to the responses when needed.
+func (c *AppClient) RespondToAuthChallenge(prev *AuthResult, responses map[string]string) (*AuthResult, error) {
+	if prev == nil || prev.ChallengeName == "" {
+		return nil, errors.New("no challenge to respond to")
+	}
+
+	all := map[string]string{"USERNAME": prev.Username}
+	c.addSecretHash(all, prev.Username)
+	device, err := c.rememberedDevice(prev.loginName)
+	if err != nil {
+		return nil, err
+	}
+	if device != nil {
+		all["DEVICE_KEY"] = device.DeviceKey
+	}
+	for k, v := range responses {
+		all[k] = v
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.respondToAuthChallenge(cip, prev, all)
+	if err != nil {
+		return nil, err
+	}
+	return c.finishAuth(cip, r)
+}
+
+func (c *AppClient) respondToAuthChallenge(cip *cognitoidentityprovider.CognitoIdentityProvider, prev *AuthResult, responses map[string]string) (*AuthResult, error) {
+	input := &cognitoidentityprovider.RespondToAuthChallengeInput{
+		ChallengeName:      aws.String(prev.ChallengeName),
+		ChallengeResponses: aws.StringMap(responses),
+		ClientId:           aws.String(c.ClientID),
+	}
+	if prev.Session != "" {
+		input.Session = aws.String(prev.Session)
+	}
+	if len(prev.ClientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(prev.ClientMetadata)
+	}
+
+	out, err := cip.RespondToAuthChallenge(input)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session), nil
+}
+
+// RefreshAuth gets new ID and access tokens with REFRESH_TOKEN_AUTH, which unlike RefreshTokens
+// does not need the hosted UI domain. username is needed for the SECRET_HASH if the client has a
+// secret, Cognito expects the sub for pools with email or phone aliases. The refresh token is not
+// rotated, the given one is returned.
+func (c *AppClient) RefreshAuth(username, refreshToken string) (*AuthResult, error) {
+	params := map[string]string{"REFRESH_TOKEN": refreshToken}
+	c.addSecretHash(params, username)
+	device, err := c.rememberedDevice(username)
+	if err != nil {
+		return nil, err
+	}
+	if device != nil {
+		params["DEVICE_KEY"] = device.DeviceKey
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.InitiateAuth(&cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow:       aws.String(cognitoidentityprovider.AuthFlowTypeRefreshTokenAuth),
+		AuthParameters: aws.StringMap(params),
+		ClientId:       aws.String(c.ClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	prev := &AuthResult{Username: username, loginName: username}
+	r := newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session)
+	if !r.Authenticated() {
+		return nil, fmt.Errorf("unexpected %s challenge refreshing tokens", r.ChallengeName)
+	}
+	if r.Token.RefreshToken == "" {
+		r.Token.RefreshToken = refreshToken
+	}
+	return r, nil
+}
+
+// finishAuth answers the challenges the client can handle itself, i.e. device SRP
+// authentication, and confirms new devices once authentication succeeded
+func (c *AppClient) finishAuth(cip *cognitoidentityprovider.CognitoIdentityProvider, r *AuthResult) (*AuthResult, error) {
+	if r.ChallengeName == cognitoidentityprovider.ChallengeNameTypeDeviceSrpAuth {
+		device, err := c.rememberedDevice(r.loginName)
+		if err != nil {
+			return nil, err
+		}
+		if device == nil {
+			return nil, fmt.Errorf("%s challenge without a remembered device", r.ChallengeName)
+		}
+		r, err = c.deviceSRPAuth(cip, r, device)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Authenticated() && r.NewDevice != nil && c.DeviceStore != nil {
+		r.DeviceError = c.confirmDevice(cip, r)
+	}
+	return r, nil
+}
@@ -0,0 +1,111 @@
+package cognito
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of calls a batch operation makes at once
+const DefaultBatchConcurrency = 4
+
+// BatchOptions controls the batch operations
+type BatchOptions struct {
+	// Concurrency is the number of users handled at once, defaults to DefaultBatchConcurrency.
+	// The calls are still paced by the client's RateLimiter.
+	Concurrency int
+}
+
+// BatchResult is the outcome of a batch operation for a user
+type BatchResult struct {
+	Username string `json:"username"`
+	OK       bool   `json:"ok"`
+	Err      error  `json:"-"`
+}
+
+// BatchReport holds the results of a batch operation in the order of the usernames
+type BatchReport struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Failed returns the results of the users the operation failed for
+func (r *BatchReport) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// BatchDeleteUsers deletes the users, continuing after failures
+// Requires a AWS session with developer credentials
+func (c *AppClient) BatchDeleteUsers(usernames []string, opts *BatchOptions) *BatchReport {
+	return runBatch(usernames, opts, c.DeleteUser)
+}
+
+// BatchConfirmUsers confirms the sign up of the users, continuing after failures
+// Requires a AWS session with developer credentials
+func (c *AppClient) BatchConfirmUsers(usernames []string, opts *BatchOptions) *BatchReport {
+	return runBatch(usernames, opts, c.ConfirmUser)
+}
+
+// BatchAddUsersToGroup adds the users to the group, continuing after failures
+// Requires a AWS session with developer credentials
+func (c *AppClient) BatchAddUsersToGroup(group string, usernames []string, opts *BatchOptions) *BatchReport {
+	return runBatch(usernames, opts, func(username string) error {
+		return c.AddUserToGroup(username, group)
+	})
+}
+
+// BatchSetPasswords sets the password of each user in passwords, keyed by username,
+// continuing after failures. The results are sorted by username.
+// Requires a AWS session with developer credentials
+func (c *AppClient) BatchSetPasswords(passwords map[string]string, permanent bool, opts *BatchOptions) *BatchReport {
+	usernames := make([]string, 0, len(passwords))
+	for username := range passwords {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	return runBatch(usernames, opts, func(username string) error {
+		return c.SetUserPassword(username, passwords[username], permanent)
+	})
+}
+
+// runBatch calls fn for every username with bounded concurrency
+func runBatch(usernames []string, opts *BatchOptions, fn func(username string) error) *BatchReport {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(usernames))}
+	forEachConcurrently(len(usernames), concurrency, func(i int) {
+		err := fn(usernames[i])
+		report.Results[i] = BatchResult{Username: usernames[i], OK: err == nil, Err: err}
+	})
+	return report
+}
+
+// forEachConcurrently calls fn for 0 to n-1 on concurrency goroutines and waits for them
+func forEachConcurrently(n, concurrency int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,49 @@
+package cognito
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatch(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	usernames := []string{"alice", "bob", "carol", "dave", "erin"}
+	report := runBatch(usernames, &BatchOptions{Concurrency: 2}, func(username string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if username == "bob" {
+			return errors.New("user not found")
+		}
+		return nil
+	})
+
+	assert.Equal(t, 2, maxRunning)
+	assert.Len(t, report.Results, len(usernames))
+	for i, res := range report.Results {
+		assert.Equal(t, usernames[i], res.Username)
+		assert.Equal(t, res.Username != "bob", res.OK)
+	}
+	failed := report.Failed()
+	assert.Len(t, failed, 1)
+	assert.Equal(t, "bob", failed[0].Username)
+	assert.EqualError(t, failed[0].Err, "user not found")
+}
+
+func TestRunBatchEmpty(t *testing.T) {
+	report := runBatch(nil, nil, func(username string) error { return nil })
+	assert.Empty(t, report.Results)
+	assert.Empty(t, report.Failed())
+}
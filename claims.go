@@ -0,0 +1,138 @@
+package cognito
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// IDClaims are the claims of a Cognito ID token
+type IDClaims struct {
+	Subject             string   `json:"sub"`
+	Username            string   `json:"cognito:username"`
+	Email               string   `json:"email,omitempty"`
+	EmailVerified       bool     `json:"email_verified,omitempty"`
+	PhoneNumber         string   `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool     `json:"phone_number_verified,omitempty"`
+	Groups              []string `json:"cognito:groups,omitempty"`
+	Audience            string   `json:"aud"`
+	Issuer              string   `json:"iss"`
+	TokenUse            string   `json:"token_use"`
+	AuthTime            int64    `json:"auth_time"`
+	IssuedAt            int64    `json:"iat"`
+	ExpiresAt           int64    `json:"exp"`
+	Nonce               string   `json:"nonce,omitempty"`
+	// Custom holds the custom attributes without the "custom:" prefix
+	Custom map[string]string `json:"-"`
+}
+
+// Expires returns the expiry of the token as a time
+func (ic *IDClaims) Expires() time.Time {
+	return time.Unix(ic.ExpiresAt, 0)
+}
+
+// InGroup reports whether the user is a member of group
+func (ic *IDClaims) InGroup(group string) bool {
+	for _, g := range ic.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// NewIDClaims converts the claims of a parsed ID token to IDClaims
+func NewIDClaims(token *jwt.Token) (*IDClaims, error) {
+	mc, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("token claims are not map claims")
+	}
+
+	ic := &IDClaims{
+		Subject:             claimString(mc, "sub"),
+		Username:            claimString(mc, "cognito:username"),
+		Email:               claimString(mc, "email"),
+		EmailVerified:       claimBool(mc, "email_verified"),
+		PhoneNumber:         claimString(mc, "phone_number"),
+		PhoneNumberVerified: claimBool(mc, "phone_number_verified"),
+		Groups:              claimStrings(mc, "cognito:groups"),
+		Audience:            claimString(mc, "aud"),
+		Issuer:              claimString(mc, "iss"),
+		TokenUse:            claimString(mc, "token_use"),
+		AuthTime:            claimInt(mc, "auth_time"),
+		IssuedAt:            claimInt(mc, "iat"),
+		ExpiresAt:           claimInt(mc, "exp"),
+		Nonce:               claimString(mc, "nonce"),
+	}
+	if ic.TokenUse != "id" {
+		return nil, fmt.Errorf("token_use is %q, not an ID token", ic.TokenUse)
+	}
+
+	for name, value := range mc {
+		if strings.HasPrefix(name, CustomAttributePrefix) {
+			if ic.Custom == nil {
+				ic.Custom = map[string]string{}
+			}
+			ic.Custom[strings.TrimPrefix(name, CustomAttributePrefix)] = fmt.Sprint(value)
+		}
+	}
+
+	return ic, nil
+}
+
+// VerifyIDToken parses and verifies an ID token and returns its claims
+func (c *AppClient) VerifyIDToken(idToken string) (*IDClaims, error) {
+	token, err := c.ParseAndVerifyJWT(idToken)
+	if err != nil {
+		return nil, err
+	}
+	return NewIDClaims(token)
+}
+
+func claimString(mc jwt.MapClaims, name string) string {
+	s, _ := mc[name].(string)
+	return s
+}
+
+// claimBool accepts booleans and the "true" strings of federated users
+func claimBool(mc jwt.MapClaims, name string) bool {
+	switch v := mc[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func claimInt(mc jwt.MapClaims, name string) int64 {
+	switch v := mc[name].(type) {
+	case float64:
+		return int64(v)
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	case int64:
+		return v
+	}
+	return 0
+}
+
+func claimStrings(mc jwt.MapClaims, name string) []string {
+	var ss []string
+	switch v := mc[name].(type) {
+	case []interface{}:
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+	case []string:
+		ss = v
+	}
+	return ss
+}
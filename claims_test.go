@@ -0,0 +1,51 @@
+package cognito_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+
+	idToken, err := issuer.MintIDToken(cognitotest.TokenOptions{
+		Subject:  "sub-1",
+		Username: "jdoe",
+		Email:    "jdoe@example.com",
+		Groups:   []string{"admins"},
+		Claims:   map[string]interface{}{"custom:tenant": "acme", "phone_number_verified": "true"},
+	})
+	assert.Nil(t, err)
+
+	claims, err := client.VerifyIDToken(idToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "sub-1", claims.Subject)
+	assert.Equal(t, "jdoe", claims.Username)
+	assert.Equal(t, "jdoe@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.True(t, claims.PhoneNumberVerified)
+	assert.True(t, claims.InGroup("admins"))
+	assert.Equal(t, issuer.ClientID, claims.Audience)
+	assert.Equal(t, issuer.IssuerURL(), claims.Issuer)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, claims.Custom)
+
+	accessToken, err := issuer.MintAccessToken(cognitotest.TokenOptions{})
+	assert.Nil(t, err)
+	_, err = client.VerifyIDToken(accessToken)
+	assert.NotNil(t, err, "Access token accepted as ID token")
+}
+
+func TestParseAndVerifyJWTWithoutKeys(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	idToken, err := issuer.MintIDToken(cognitotest.TokenOptions{})
+	assert.Nil(t, err)
+
+	client := issuer.NewAppClient()
+	client.WellKnownJWKs = nil
+	_, err = client.ParseAndVerifyJWT(idToken)
+	assert.NotNil(t, err)
+}
@@ -9,15 +9,18 @@ import (
 	b64 "encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
 
@@ -43,6 +46,26 @@ type AppClient struct {
 	LogoutRedirectURI        string
 	TokenEndpoint            string
 	Base64BasicAuthorization string
+	DeviceStore              DeviceStore
+	DeviceName               string
+	// RateLimiter paces the Cognito calls by quota category, nil turns off the limit
+	RateLimiter *RateLimiter
+	// MaxRetries is the number of retries of a throttled call, DefaultMaxRetries if 0
+	// and none if negative
+	MaxRetries int
+	Hooks      *Hooks
+	// AWSSession is the base session of the AWS calls, built from the credential
+	// settings below on first use if nil
+	AWSSession           *session.Session
+	AWSProfile           string
+	CredentialsProvider  credentials.Provider
+	AssumeRoleARN        string
+	ExternalID           string
+	RoleSessionName      string
+	WebIdentityTokenFile string
+
+	sessionMu sync.Mutex
+	session   *session.Session
 }
 
 // AppClientConfig defines required info to build a new AppClient
@@ -56,8 +79,34 @@ type AppClientConfig struct {
 	ClientSecret       string                 `json:"clientSecret"`
 	RedirectURI        string                 `json:"redirectUri"`
 	LogoutRedirectURI  string                 `json:"logoutRedirectUri"`
+	DeviceName         string                 `json:"deviceName"`
+	DeviceStore        DeviceStore            `json:"-"`
+	RateLimiter        *RateLimiter           `json:"-"` // defaults to NewRateLimiter(nil)
+	MaxRetries         int                    `json:"maxRetries"`
+	Hooks              *Hooks                 `json:"-"`
 	TraceContext       context.Context        `json:"-"`
 	AWSClientTracer    func(c *client.Client) `json:"-"`
+	// Strict makes NewAppClient fail if the config is invalid, see Validate, or the well
+	// known JWKs cannot be fetched
+	Strict bool `json:"strict"`
+
+	// AWSSession is the base session of the AWS calls, instead of a session built from
+	// the profile, the credentials provider or the access keys
+	AWSSession *session.Session `json:"-"`
+	// AWSProfile is a profile of the shared config and credentials files
+	AWSProfile string `json:"awsProfile"`
+	// CredentialsProvider provides the credentials, it wins over the access keys
+	CredentialsProvider credentials.Provider `json:"-"`
+	// AssumeRoleARN is a role assumed with the credentials above, or with the web identity
+	// token if WebIdentityTokenFile is set
+	AssumeRoleARN string `json:"assumeRoleArn"`
+	// ExternalID is passed when assuming the role, if the role's trust policy requires it
+	ExternalID string `json:"externalId"`
+	// RoleSessionName names the role session, a timestamp if empty
+	RoleSessionName string `json:"roleSessionName"`
+	// WebIdentityTokenFile is an OIDC token file, e.g. of an EKS service account (IRSA),
+	// to assume AssumeRoleARN with
+	WebIdentityTokenFile string `json:"webIdentityTokenFile"`
 }
 
 // Token defines a token struct for JSON responses from Cognito TOKEN endpoint
@@ -75,9 +124,31 @@ type Credentials struct {
 	Password string `json:"password"`
 }
 
-// NewAppClient returns a new AppClient interface configured for the given Cognito user pool and client
+// NewAppClient returns a new AppClient interface configured for the given Cognito user pool and client.
+// If the well known JWKs cannot be fetched the client is returned with the error, unless
+// cfg.Strict is set. In strict mode an invalid config or a failed fetch return no client.
 func NewAppClient(cfg *AppClientConfig) (*AppClient, error) {
-	var err error
+	if cfg.Strict {
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	c := newAppClient(cfg)
+
+	// Set the well known JSON web token key sets
+	err := c.getWellKnownJWTKs()
+	if err != nil {
+		log.Println("Error getting well known JWTKs", err)
+		if cfg.Strict {
+			return nil, fmt.Errorf("could not fetch the well known JWKs of %s: %v", c.UserPoolID, err)
+		}
+	}
+
+	return c, err
+}
+
+// newAppClient sets up the client without calling AWS
+func newAppClient(cfg *AppClientConfig) *AppClient {
 	c := &AppClient{
 		AWSAccessKey:       cfg.AWSAccessKey,
 		AWSSecretAccessKey: cfg.AWSSecretAccessKey,
@@ -88,6 +159,22 @@ func NewAppClient(cfg *AppClientConfig) (*AppClient, error) {
 		Domain:             cfg.Domain,
 		RedirectURI:        cfg.RedirectURI,
 		LogoutRedirectURI:  cfg.LogoutRedirectURI,
+		DeviceStore:        cfg.DeviceStore,
+		DeviceName:         cfg.DeviceName,
+		RateLimiter:        cfg.RateLimiter,
+		MaxRetries:         cfg.MaxRetries,
+		Hooks:              cfg.Hooks,
+
+		AWSSession:           cfg.AWSSession,
+		AWSProfile:           cfg.AWSProfile,
+		CredentialsProvider:  cfg.CredentialsProvider,
+		AssumeRoleARN:        cfg.AssumeRoleARN,
+		ExternalID:           cfg.ExternalID,
+		RoleSessionName:      cfg.RoleSessionName,
+		WebIdentityTokenFile: cfg.WebIdentityTokenFile,
+	}
+	if c.RateLimiter == nil {
+		c.RateLimiter = NewRateLimiter(nil)
 	}
 
 	if c.ClientSecret != "" {
@@ -103,18 +190,12 @@ func NewAppClient(cfg *AppClientConfig) (*AppClient, error) {
 		buffer.WriteString(base64AuthStr)
 		c.Base64BasicAuthorization = buffer.String()
 		buffer.Reset()
-
-		// Set up login and signup URLs, if there is a domain available
-		c.getURLs()
-	}
-
-	// Set the well known JSON web token key sets
-	err = c.getWellKnownJWTKs()
-	if err != nil {
-		log.Println("Error getting well known JWTKs", err)
 	}
 
-	return c, err
+	// Set up login and signup URLs, if there is a domain available. Public clients without
+	// a secret use them as well, with PKCE.
+	c.getURLs()
+	return c
 }
 
 // getWellKnownJWTKs gets the well known JSON web token key set for this client's user pool
@@ -141,6 +222,7 @@ func (c *AppClient) getWellKnownJWTKs() error {
 }
 
 // getURLs gets all of the URLs and endpoints for the Cognito client, AWS hosted login/signup pages, token endpoints for oauth2, etc.
+// The client id and redirect URI are query escaped.
 func (c *AppClient) getURLs() {
 	if c.Domain != "" {
 		// Get the base URL
@@ -157,27 +239,27 @@ func (c *AppClient) getURLs() {
 		// Set the HostedLoginURL
 		buffer.WriteString(baseURL)
 		buffer.WriteString("/login?response_type=code&client_id=")
-		buffer.WriteString(c.ClientID)
+		buffer.WriteString(url.QueryEscape(c.ClientID))
 		buffer.WriteString("&redirect_uri=")
-		buffer.WriteString(c.RedirectURI)
+		buffer.WriteString(url.QueryEscape(c.RedirectURI))
 		c.HostedLoginURL = buffer.String()
 		buffer.Reset()
 
 		// Set the HostedLogoutURL
 		buffer.WriteString(baseURL)
 		buffer.WriteString("/logout?response_type=code&client_id=")
-		buffer.WriteString(c.ClientID)
+		buffer.WriteString(url.QueryEscape(c.ClientID))
 		buffer.WriteString("&redirect_uri=")
-		buffer.WriteString(c.RedirectURI)
+		buffer.WriteString(url.QueryEscape(c.RedirectURI))
 		c.HostedLogoutURL = buffer.String()
 		buffer.Reset()
 
 		// Set the HostedSignUpURL
 		buffer.WriteString(baseURL)
 		buffer.WriteString("/signup?response_type=code&client_id=")
-		buffer.WriteString(c.ClientID)
+		buffer.WriteString(url.QueryEscape(c.ClientID))
 		buffer.WriteString("&redirect_uri=")
-		buffer.WriteString(c.RedirectURI)
+		buffer.WriteString(url.QueryEscape(c.RedirectURI))
 		c.HostedSignUpURL = buffer.String()
 		buffer.Reset()
 
@@ -191,24 +273,58 @@ func (c *AppClient) getURLs() {
 
 // GetTokens will make a POST request to the Cognito TOKEN endpoint to exchange a code for an access token
 func (c *AppClient) GetTokens(code string, scope []string) (Token, error) {
-	var token Token
+	return c.GetTokensPKCE(code, "", scope)
+}
 
-	hc := http.Client{}
+// GetTokensPKCE exchanges a code like GetTokens, sending the PKCE code verifier of the
+// login if it is not empty
+func (c *AppClient) GetTokensPKCE(code, codeVerifier string, scope []string) (Token, error) {
 	// set the url-encoded payload
 	form := url.Values{}
 	form.Set("code", code)
 	form.Set("grant_type", "authorization_code")
 	form.Set("client_id", c.ClientID)
 	form.Set("redirect_uri", c.RedirectURI)
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
 	if len(scope) > 0 {
 		form.Set("scope", strings.Join(scope, " "))
 	}
+	return c.requestTokens(form)
+}
+
+// RefreshTokens will make a POST request to the Cognito TOKEN endpoint to exchange a refresh token
+// for new ID and access tokens. Cognito does not rotate the refresh token, the given one is returned.
+func (c *AppClient) RefreshTokens(refreshToken string) (Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", c.ClientID)
+	form.Set("refresh_token", refreshToken)
+
+	token, err := c.requestTokens(form)
+	if err == nil && token.Error != "" {
+		err = errors.New("could not refresh tokens: " + token.Error)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, err
+}
+
+// requestTokens posts the form to the Cognito TOKEN endpoint
+func (c *AppClient) requestTokens(form url.Values) (Token, error) {
+	var token Token
+
+	hc := http.Client{}
 	// request
 	req, err := http.NewRequest("POST", c.TokenEndpoint, strings.NewReader(form.Encode()))
 	if err == nil {
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		// This should be a string like: Basic XXXXXXXXXX
-		req.Header.Add("Authorization", c.Base64BasicAuthorization)
+		// This should be a string like: Basic XXXXXXXXXX, public clients have no secret
+		if c.Base64BasicAuthorization != "" {
+			req.Header.Add("Authorization", c.Base64BasicAuthorization)
+		}
 
 		resp, err := hc.Do(req)
 		if err != nil {
@@ -248,22 +364,7 @@ func (c *AppClient) GetTokens(code string, scope []string) (Token, error) {
 //  - The audience ("aud") in the payload matches the app client ID created in the Cognito user pool.
 func (c *AppClient) ParseAndVerifyJWT(t string) (*jwt.Token, error) {
 	// 3 tokens are returned from the Cognito TOKEN endpoint; "id_token" "access_token" and "refresh_token"
-	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
-		// Looking up the key id will return an array of just one key
-		keys := c.WellKnownJWKs.LookupKeyID(token.Header["kid"].(string))
-		if len(keys) == 0 {
-			log.Println("Failed to look up JWKs")
-			return nil, errors.New("could not find matching `kid` in well known tokens")
-		}
-		// Build the public RSA key
-		key, err := keys[0].Materialize()
-		if err != nil {
-			log.Printf("Failed to create public key: %s", err)
-			return nil, err
-		}
-		rsaPublicKey := key.(*rsa.PublicKey)
-		return rsaPublicKey, nil
-	})
+	token, err := jwt.Parse(t, KeyFunc(c.WellKnownJWKs))
 
 	// Populated when you Parse/Verify a token
 	// First verify the token itself is a valid format
@@ -292,63 +393,168 @@ func (c *AppClient) ParseAndVerifyJWT(t string) (*jwt.Token, error) {
 	return nil, err
 }
 
-func (c *AppClient) NewCIP() (cip *cognitoidentityprovider.CognitoIdentityProvider, err error) {
-
-	var ses *session.Session
+// KeyFunc returns a jwt.Keyfunc that checks the token is signed with RS256, as Cognito
+// does, and looks up the public key of its kid in keys
+func KeyFunc(keys *jwk.Set) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected alg %v, Cognito signs with RS256", token.Header["alg"])
+		}
+		if keys == nil {
+			return nil, errors.New("no well known JWKs loaded")
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no `kid` header")
+		}
+		return rsaPublicKey(keys, kid)
+	}
+}
 
-	// Setup the AWS session with or without AWS credentials:
-	if c.AWSAccessKey != "" && c.AWSSecretAccessKey != "" {
-		credentials := credentials.NewStaticCredentials(c.AWSAccessKey, c.AWSSecretAccessKey, "")
-		ses, err = session.NewSession(&aws.Config{
-			Credentials: credentials,
-			Region:      aws.String(c.Region),
-		})
-	} else {
-		ses, err = session.NewSession(&aws.Config{
-			Region: aws.String(c.Region),
-		})
+// rsaPublicKey returns the RSA public key with the kid
+func rsaPublicKey(keys *jwk.Set, kid string) (*rsa.PublicKey, error) {
+	// Looking up the key id will return an array of just one key
+	found := keys.LookupKeyID(kid)
+	if len(found) == 0 {
+		log.Println("Failed to look up JWKs")
+		return nil, errors.New("could not find matching `kid` in well known tokens")
+	}
+	// Build the public RSA key
+	key, err := found[0].Materialize()
+	if err != nil {
+		log.Printf("Failed to create public key: %s", err)
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("well known key is not an RSA public key")
 	}
+	return rsaKey, nil
+}
+
+func (c *AppClient) NewCIP() (cip *cognitoidentityprovider.CognitoIdentityProvider, err error) {
+	ses, err := c.awsSession()
 	if err != nil {
 		return cip, err
 	}
 
 	// Create the CognitoIdentityProvider Client
 	cip = cognitoidentityprovider.New(ses)
+	c.throttle(cip.Client)
 	return cip, err
 }
 
-func (c *AppClient) AuthenticateUserPassword(credentials *Credentials) (cognitoID string, err error) {
-	username := aws.String(credentials.Username)
-	password := aws.String(credentials.Password)
-	clientID := aws.String(c.ClientID)
-
-	params := &cognitoidentityprovider.InitiateAuthInput{
-		AuthFlow: aws.String("USER_PASSWORD_AUTH"),
-		AuthParameters: map[string]*string{
-			"USERNAME": username,
-			"PASSWORD": password,
-		},
-		ClientId: clientID,
+// awsSession returns the AWS session of the client. The session is built on first use from,
+// in this order, AWSSession, the CredentialsProvider, the access keys or the default credential
+// chain with the AWSProfile. If a role is configured, its credentials replace the ones of the
+// base session and are refreshed before they expire.
+func (c *AppClient) awsSession() (*session.Session, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.session != nil {
+		return c.session, nil
+	}
+
+	ses := c.AWSSession
+	if ses == nil {
+		opts := session.Options{
+			Config:  aws.Config{Region: aws.String(c.Region)},
+			Profile: c.AWSProfile,
+		}
+		if c.AWSProfile != "" {
+			opts.SharedConfigState = session.SharedConfigEnable
+		}
+		// Setup the AWS session with or without AWS credentials:
+		if c.CredentialsProvider != nil {
+			opts.Config.Credentials = credentials.NewCredentials(c.CredentialsProvider)
+		} else if c.AWSAccessKey != "" && c.AWSSecretAccessKey != "" {
+			opts.Config.Credentials = credentials.NewStaticCredentials(c.AWSAccessKey, c.AWSSecretAccessKey, "")
+		}
+		var err error
+		if ses, err = session.NewSessionWithOptions(opts); err != nil {
+			return nil, err
+		}
+	} else if c.Region != "" {
+		ses = ses.Copy(&aws.Config{Region: aws.String(c.Region)})
 	}
 
+	creds, err := c.roleCredentials(ses)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		ses = ses.Copy(&aws.Config{Credentials: creds})
+	}
+	c.session = ses
+	return ses, nil
+}
+
+// roleCredentials returns the credentials of the configured role, nil if there is none
+func (c *AppClient) roleCredentials(base *session.Session) (*credentials.Credentials, error) {
+	if c.WebIdentityTokenFile != "" {
+		if c.AssumeRoleARN == "" {
+			return nil, errors.New("a web identity token file needs AssumeRoleARN")
+		}
+		return stscreds.NewWebIdentityCredentials(base, c.AssumeRoleARN, c.RoleSessionName, c.WebIdentityTokenFile), nil
+	}
+	if c.AssumeRoleARN == "" {
+		return nil, nil
+	}
+	return stscreds.NewCredentials(base, c.AssumeRoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = c.RoleSessionName
+		if c.ExternalID != "" {
+			p.ExternalID = aws.String(c.ExternalID)
+		}
+	}), nil
+}
+
+// AuthenticatePassword authenticates a user with USER_PASSWORD_AUTH. On success the result
+// holds the ID, access and refresh tokens and the verified claims of the ID token, otherwise
+// the challenge to answer with RespondToAuthChallenge.
+func (c *AppClient) AuthenticatePassword(credentials *Credentials) (*AuthResult, error) {
+	params := map[string]string{
+		"USERNAME": credentials.Username,
+		"PASSWORD": credentials.Password,
+	}
+	c.addSecretHash(params, credentials.Username)
+
 	// Create the CognitoIdentityProvider
 	cip, err := c.NewCIP()
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	// Authenticate
-	token, err := cip.InitiateAuth(params)
+	out, err := cip.InitiateAuth(&cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow:       aws.String("USER_PASSWORD_AUTH"),
+		AuthParameters: aws.StringMap(params),
+		ClientId:       aws.String(c.ClientID),
+	})
 	if err != nil {
-		return
+		return nil, err
 	}
+	prev := &AuthResult{Username: credentials.Username, loginName: credentials.Username}
+	r := newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session)
 
-	// Now we need to get the cognito id from the IDToken Claims (sub)
-	idToken, err := c.ParseAndVerifyJWT(*token.AuthenticationResult.IdToken)
-	if idClaims, ok := idToken.Claims.(jwt.MapClaims); ok && idToken.Valid {
-		cognitoID = idClaims["sub"].(string)
+	if r.Authenticated() {
+		r.IDClaims, err = c.VerifyIDToken(r.Token.IDToken)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return r, nil
+}
 
-	return cognitoID, err
+// AuthenticateUserPassword authenticates a user with USER_PASSWORD_AUTH and returns the cognito id (sub)
+// of the user. Use AuthenticatePassword to get the tokens.
+func (c *AppClient) AuthenticateUserPassword(credentials *Credentials) (cognitoID string, err error) {
+	r, err := c.AuthenticatePassword(credentials)
+	if err != nil {
+		return
+	}
+	if !r.Authenticated() {
+		return "", fmt.Errorf("authentication requires the %s challenge", r.ChallengeName)
+	}
 
+	return r.IDClaims.Subject, nil
 }
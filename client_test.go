@@ -0,0 +1,221 @@
+package cognito
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider returns fixed credentials and counts the calls
+type fakeProvider struct {
+	accessKeyID string
+	retrieved   int
+}
+
+func (p *fakeProvider) Retrieve() (credentials.Value, error) {
+	p.retrieved++
+	return credentials.Value{AccessKeyID: p.accessKeyID, SecretAccessKey: "secret", ProviderName: "fake"}, nil
+}
+
+func (p *fakeProvider) IsExpired() bool { return p.retrieved == 0 }
+
+// stsServer answers AssumeRole and AssumeRoleWithWebIdentity, passing the form of the
+// request to check
+func stsServer(t *testing.T, check func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		check(r)
+		action := r.FormValue("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<` + action + `Response xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><` + action + `Result>
+<Credentials><AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey>
+<SessionToken>role-token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>
+</` + action + `Result></` + action + `Response>`))
+	}))
+}
+
+func TestAWSSessionCredentials(t *testing.T) {
+	fake := &fakeProvider{accessKeyID: "AKIAFAKE"}
+	c := &AppClient{Region: "us-east-1", CredentialsProvider: fake, AWSAccessKey: "AKIASTATIC", AWSSecretAccessKey: "secret"}
+	ses, err := c.awsSession()
+	assert.Nil(t, err)
+	v, err := ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIAFAKE", v.AccessKeyID, "Provider does not win over the keys")
+	assert.Equal(t, "us-east-1", aws.StringValue(ses.Config.Region))
+
+	again, err := c.awsSession()
+	assert.Nil(t, err)
+	assert.True(t, ses == again, "Session not reused")
+
+	c = &AppClient{Region: "us-east-1", AWSAccessKey: "AKIASTATIC", AWSSecretAccessKey: "secret"}
+	ses, err = c.awsSession()
+	assert.Nil(t, err)
+	v, err = ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIASTATIC", v.AccessKeyID)
+
+	// A prebuilt session is used with the client's region
+	base := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewCredentials(fake),
+	}))
+	c = &AppClient{Region: "us-east-1", AWSSession: base}
+	ses, err = c.awsSession()
+	assert.Nil(t, err)
+	assert.Equal(t, "us-east-1", aws.StringValue(ses.Config.Region))
+	v, err = ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIAFAKE", v.AccessKeyID)
+}
+
+func TestAWSSessionProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cognito")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(file, []byte("[deploy]\naws_access_key_id = AKIAPROFILE\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"AWS_SHARED_CREDENTIALS_FILE": file, "AWS_CONFIG_FILE": filepath.Join(dir, "config")} {
+		old, set := os.LookupEnv(key)
+		os.Setenv(key, value)
+		if set {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+
+	c := &AppClient{Region: "us-east-1", AWSProfile: "deploy"}
+	ses, err := c.awsSession()
+	assert.Nil(t, err)
+	v, err := ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "AKIAPROFILE", v.AccessKeyID)
+}
+
+func TestAWSSessionAssumeRole(t *testing.T) {
+	srv := stsServer(t, func(r *http.Request) {
+		assert.Equal(t, "AssumeRole", r.FormValue("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/cognito-admin", r.FormValue("RoleArn"))
+		assert.Equal(t, "tenant-42", r.FormValue("ExternalId"))
+		assert.Equal(t, "bulk-import", r.FormValue("RoleSessionName"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKIAFAKE/", "Role not assumed with the base credentials")
+	})
+	defer srv.Close()
+
+	base := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewCredentials(&fakeProvider{accessKeyID: "AKIAFAKE"}),
+	}))
+	c := &AppClient{
+		AWSSession:      base,
+		AssumeRoleARN:   "arn:aws:iam::123456789012:role/cognito-admin",
+		ExternalID:      "tenant-42",
+		RoleSessionName: "bulk-import",
+	}
+	ses, err := c.awsSession()
+	assert.Nil(t, err)
+	v, err := ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "ASIAROLE", v.AccessKeyID)
+	assert.Equal(t, "role-token", v.SessionToken)
+}
+
+func TestAWSSessionWebIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cognito")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err = ioutil.WriteFile(tokenFile, []byte("oidc-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := stsServer(t, func(r *http.Request) {
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.FormValue("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/irsa", r.FormValue("RoleArn"))
+		assert.Equal(t, "oidc-token", strings.TrimSpace(r.FormValue("WebIdentityToken")))
+	})
+	defer srv.Close()
+
+	base := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.AnonymousCredentials,
+	}))
+	c := &AppClient{AWSSession: base, WebIdentityTokenFile: tokenFile, AssumeRoleARN: "arn:aws:iam::123456789012:role/irsa"}
+	ses, err := c.awsSession()
+	assert.Nil(t, err)
+	v, err := ses.Config.Credentials.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "ASIAROLE", v.AccessKeyID)
+
+	c = &AppClient{AWSSession: base, WebIdentityTokenFile: tokenFile}
+	_, err = c.awsSession()
+	assert.EqualError(t, err, "a web identity token file needs AssumeRoleARN")
+}
+
+func TestNewAppClientStrict(t *testing.T) {
+	// Strict mode fails before fetching the JWKS
+	c, err := NewAppClient(&AppClientConfig{Region: "us-east-1", PoolID: "eu-west-1_Pool", Strict: true})
+	assert.Nil(t, c)
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, []FieldError{
+			{"poolId", "pool eu-west-1_Pool is in eu-west-1, not in region us-east-1"},
+			{"clientId", "is required"},
+		}, err.(*ConfigError).Errors)
+	}
+}
+
+func TestHostedURLsWithoutSecret(t *testing.T) {
+	c := newAppClient(&AppClientConfig{
+		Region:      "us-east-1",
+		PoolID:      "us-east-1_Pool",
+		ClientID:    "public",
+		Domain:      "myapp",
+		RedirectURI: "http://localhost:8400/callback",
+	})
+	assert.Empty(t, c.Base64BasicAuthorization)
+	assert.Equal(t, "https://myapp.auth.us-east-1.amazoncognito.com", c.BaseURL)
+	assert.Equal(t, "https://myapp.auth.us-east-1.amazoncognito.com/login?response_type=code&client_id=public&redirect_uri=http%3A%2F%2Flocalhost%3A8400%2Fcallback", c.HostedLoginURL)
+	assert.Equal(t, "https://myapp.auth.us-east-1.amazoncognito.com/oauth2/token", c.TokenEndpoint)
+
+	// A redirect URI with a query keeps its parameters out of the login URL's
+	c.RedirectURI = "https://app.example.com/callback?tenant=acme&next=/home"
+	c.getURLs()
+	u, err := url.Parse(c.HostedLoginURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://app.example.com/callback?tenant=acme&next=/home", u.Query().Get("redirect_uri"))
+	assert.Empty(t, u.Query().Get("next"))
+
+	c = newAppClient(&AppClientConfig{Region: "us-east-1", ClientID: "confidential", ClientSecret: "secret"})
+	assert.Equal(t, "Basic Y29uZmlkZW50aWFsOnNlY3JldA==", c.Base64BasicAuthorization)
+	assert.Empty(t, c.HostedLoginURL, "URLs set without a domain")
+}
+
+func TestParseAndVerifyJWTRequiresRS256(t *testing.T) {
+	c := &AppClient{ClientID: "client", WellKnownJWKs: &jwk.Set{}}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"aud": "client"}).SignedString([]byte("secret"))
+	assert.Nil(t, err)
+	_, err = c.ParseAndVerifyJWT(forged)
+	assert.EqualError(t, err, "unexpected alg HS256, Cognito signs with RS256")
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+
+	"github.com/joescharf/cognito"
+)
+
+// Login modes of auth login
+const (
+	modeSRP      = "srp"
+	modePassword = "password"
+	modeHosted   = "hosted"
+)
+
+// hostedLoginTimeout is how long auth login waits for the hosted UI callback
+const hostedLoginTimeout = 5 * time.Minute
+
+// maxChallenges stops a login that keeps returning challenges
+const maxChallenges = 10
+
+// tokenTable shows the tokens returned by a login
+func tokenTable(t *cognito.Token) *table {
+	return &table{
+		header: []string{"NAME", "VALUE"},
+		rows: [][]string{
+			{"id_token", t.IDToken},
+			{"access_token", t.AccessToken},
+			{"refresh_token", t.RefreshToken},
+			{"expires_in", strconv.Itoa(t.ExpiresIn)},
+			{"token_type", t.TokenType},
+		},
+	}
+}
+
+func authLogin(args []string) error {
+	fs, cf := newFlagSet("auth login", "")
+	mode := fs.String("mode", modeSRP, "srp or password to sign in here, hosted to sign in with the hosted UI")
+	username := fs.String("username", "", "username, prompted if empty (env COGNITO_USERNAME)")
+	password := fs.String("password", "", "password, prompted if empty (env COGNITO_PASSWORD)")
+	profileName := fs.String("profile", "", "token cache profile (env COGNITO_PROFILE, default \"default\")")
+	port := fs.Int("port", 8400, "port of the hosted UI redirect listener, http://localhost:<port>/callback must be a callback URL of the app client")
+	noBrowser := fs.Bool("no-browser", false, "print the hosted UI URL instead of opening a browser")
+	scope := fs.String("scope", "", "space separated scopes to request with the hosted UI")
+	quiet := fs.Bool("quiet", false, "only cache the tokens, do not print them")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	profile, err := profileFlag(*profileName)
+	if err != nil {
+		return err
+	}
+
+	var token *cognito.Token
+	var user string
+	var c *cognito.AppClient
+	switch *mode {
+	case modeSRP, modePassword:
+		if c, err = cf.client(true); err != nil {
+			return err
+		}
+		token, user, err = passwordLogin(c, *mode, *username, *password)
+	case modeHosted:
+		cf.flags.RedirectURI = fmt.Sprintf("http://%s/callback", callbackAddr(*port))
+		if c, err = cf.client(true); err != nil {
+			return err
+		}
+		token, user, err = hostedLogin(c, *port, !*noBrowser, strings.Fields(*scope))
+	default:
+		return usageError(fmt.Sprintf("unknown mode %q, use srp, password or hosted", *mode))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = saveCache(profile, newCachedToken(token, user, c, time.Now())); err != nil {
+		return fmt.Errorf("could not cache tokens: %v", err)
+	}
+	fmt.Fprintf(stderr, "Logged in as %s, tokens cached for profile %s\n", user, profile)
+	if *quiet {
+		return nil
+	}
+	return cf.print(tokenTable(token), token)
+}
+
+// passwordLogin signs in with SRP or USER_PASSWORD_AUTH and answers the challenges interactively
+func passwordLogin(c *cognito.AppClient, mode, username, password string) (*cognito.Token, string, error) {
+	var err error
+	if username == "" {
+		username = os.Getenv("COGNITO_USERNAME")
+	}
+	if username == "" {
+		if username, err = prompt("Username"); err != nil {
+			return nil, "", err
+		}
+	}
+	if password == "" {
+		password = os.Getenv("COGNITO_PASSWORD")
+	}
+	if password == "" {
+		if password, err = promptSecret("Password"); err != nil {
+			return nil, "", err
+		}
+	}
+
+	creds := &cognito.Credentials{Username: username, Password: password}
+	var r *cognito.AuthResult
+	if mode == modeSRP {
+		r, err = c.AuthenticateSRP(creds)
+	} else {
+		r, err = c.AuthenticatePassword(creds)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := 0; !r.Authenticated(); i++ {
+		if i == maxChallenges {
+			return nil, "", errors.New("too many challenges")
+		}
+		if r, err = answerChallenge(c, r); err != nil {
+			return nil, "", err
+		}
+	}
+	return r.Token, r.Username, nil
+}
+
+// answerChallenge prompts for the answer to the challenge of r and responds with it
+func answerChallenge(c *cognito.AppClient, r *cognito.AuthResult) (*cognito.AuthResult, error) {
+	var err error
+	responses := map[string]string{}
+	switch r.ChallengeName {
+	case cognitoidentityprovider.ChallengeNameTypeSmsMfa:
+		label := "SMS code"
+		if dest := r.ChallengeParameters["CODE_DELIVERY_DESTINATION"]; dest != "" {
+			label += " sent to " + dest
+		}
+		responses["SMS_MFA_CODE"], err = prompt(label)
+
+	case cognitoidentityprovider.ChallengeNameTypeSoftwareTokenMfa:
+		responses["SOFTWARE_TOKEN_MFA_CODE"], err = prompt("Authenticator code")
+
+	case cognitoidentityprovider.ChallengeNameTypeSelectMfaType:
+		var choices []string
+		json.Unmarshal([]byte(r.ChallengeParameters["MFAS_CAN_CHOOSE"]), &choices)
+		responses["ANSWER"], err = prompt("MFA type (" + strings.Join(choices, ", ") + ")")
+
+	case cognitoidentityprovider.ChallengeNameTypeNewPasswordRequired:
+		responses["NEW_PASSWORD"], err = promptSecret("New password")
+
+	case cognitoidentityprovider.ChallengeNameTypeMfaSetup:
+		// Register an authenticator app, the verified session answers the challenge
+		st, err := c.AssociateSoftwareTokenWithSession(r.Session, "Cognito", r.Username)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(stderr, "Set up an authenticator app with the secret %s\nor the URI %s\n", st.SecretCode, st.ProvisioningURI)
+		code, err := prompt("Authenticator code")
+		if err != nil {
+			return nil, err
+		}
+		if r.Session, err = c.VerifySoftwareTokenWithSession(st.Session, code, cognito.DefaultDeviceName); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("the %s challenge is not supported by the CLI", r.ChallengeName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.RespondToAuthChallenge(r, responses)
+}
+
+// callbackAddr is where the hosted UI redirects to. Cognito only allows plain http for
+// localhost, so the listener and the redirect URI both use it.
+func callbackAddr(port int) string {
+	return fmt.Sprintf("localhost:%d", port)
+}
+
+// hostedLogin signs in with the hosted UI using PKCE, receiving the code on a loopback listener
+func hostedLogin(c *cognito.AppClient, port int, browser bool, scope []string) (*cognito.Token, string, error) {
+	if c.HostedLoginURL == "" {
+		return nil, "", errors.New("hosted login needs the hosted UI domain, see -domain")
+	}
+	store := cognito.NewMemoryLoginAttemptStore()
+	a, err := cognito.NewLoginAttempt("")
+	if err != nil {
+		return nil, "", err
+	}
+	if err = a.UsePKCE(); err != nil {
+		return nil, "", err
+	}
+	if err = store.Save(a); err != nil {
+		return nil, "", err
+	}
+
+	ln, err := net.Listen("tcp", callbackAddr(port))
+	if err != nil {
+		return nil, "", err
+	}
+	type result struct {
+		token  cognito.Token
+		claims *cognito.IDClaims
+		err    error
+	}
+	done := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		if e := q.Get("error"); e != "" {
+			res.err = fmt.Errorf("hosted UI login failed: %s %s", e, q.Get("error_description"))
+		} else {
+			_, res.token, res.claims, res.err = c.CompleteLogin(store, q.Get("state"), q.Get("code"), scope)
+		}
+		if res.err != nil {
+			http.Error(w, "Login failed, see the terminal.", http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login complete, you can close this window.")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Shutdown(context.Background())
+
+	loginURL := c.LoginURL(a)
+	if !browser || openBrowser(loginURL) != nil {
+		fmt.Fprintf(stderr, "Open this URL to log in:\n%s\n", loginURL)
+	}
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, "", res.err
+		}
+		return &res.token, res.claims.Username, nil
+	case <-time.After(hostedLoginTimeout):
+		return nil, "", errors.New("timed out waiting for the hosted UI login")
+	}
+}
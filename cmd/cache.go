@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joescharf/cognito"
+)
+
+// defaultProfile is the token cache profile used without -profile or COGNITO_PROFILE
+const defaultProfile = "default"
+
+// errNotLoggedIn is returned when the profile has no cached tokens
+var errNotLoggedIn = errors.New("not logged in, run cognito auth login")
+
+// cachedToken is the token cache file of a profile. It holds the refresh token,
+// so it is only readable by the user.
+type cachedToken struct {
+	cognito.Token
+	Expiry   time.Time `json:"expiry"`
+	Username string    `json:"username"`
+	Region   string    `json:"region"`
+	PoolID   string    `json:"poolId"`
+	ClientID string    `json:"clientId"`
+}
+
+// newCachedToken records the tokens and the pool they belong to
+func newCachedToken(t *cognito.Token, username string, c *cognito.AppClient, now time.Time) *cachedToken {
+	return &cachedToken{
+		Token:    *t,
+		Expiry:   now.Add(time.Duration(t.ExpiresIn) * time.Second),
+		Username: username,
+		Region:   c.Region,
+		PoolID:   c.UserPoolID,
+		ClientID: c.ClientID,
+	}
+}
+
+// profileFlag returns the profile set by flag or COGNITO_PROFILE
+func profileFlag(flagValue string) (string, error) {
+	profile := flagValue
+	if profile == "" {
+		profile = os.Getenv("COGNITO_PROFILE")
+	}
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if strings.ContainsAny(profile, `/\`) || strings.HasPrefix(profile, ".") {
+		return "", usageError(fmt.Sprintf("invalid profile name %q", profile))
+	}
+	return profile, nil
+}
+
+// cachePath returns the cache file of the profile, in COGNITO_CACHE_DIR or the user cache directory
+func cachePath(profile string) (string, error) {
+	dir := os.Getenv("COGNITO_CACHE_DIR")
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "cognito")
+	}
+	return filepath.Join(dir, profile+".json"), nil
+}
+
+// saveCache writes the tokens of the profile, readable only by the user
+func saveCache(profile string, ct *cachedToken) error {
+	path, err := cachePath(profile)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(ct, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a new file and rename it, so a crash cannot leave a truncated cache
+	// and an existing file with wider permissions is replaced
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+profile+"-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadCache reads the tokens of the profile, errNotLoggedIn if there are none
+func loadCache(profile string) (*cachedToken, error) {
+	path, err := cachePath(profile)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	ct := &cachedToken{}
+	if err = json.Unmarshal(b, ct); err != nil {
+		return nil, fmt.Errorf("invalid token cache %s: %v", path, err)
+	}
+	return ct, nil
+}
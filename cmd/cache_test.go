@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+)
+
+func TestTokenCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cognito-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("COGNITO_CACHE_DIR", dir)
+	defer os.Unsetenv("COGNITO_CACHE_DIR")
+
+	_, err = loadCache("dev")
+	assert.Equal(t, errNotLoggedIn, err)
+
+	c := &cognito.AppClient{Region: "us-east-1", UserPoolID: "us-east-1_Pool", ClientID: "client"}
+	now := time.Now()
+	ct := newCachedToken(&cognito.Token{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600}, "jdoe", c, now)
+	assert.Nil(t, saveCache("dev", ct))
+	assert.Nil(t, saveCache("dev", ct), "Existing cache not replaced")
+
+	path, _ := cachePath("dev")
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	got, err := loadCache("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "refresh", got.RefreshToken)
+	assert.Equal(t, "us-east-1_Pool", got.PoolID)
+	assert.True(t, got.Expiry.Equal(now.Add(time.Hour)))
+
+	// A fresh token is printed without refreshing
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	assert.Equal(t, 0, run([]string{"token", "-profile", "dev"}))
+	assert.Equal(t, "access\n", out.String())
+}
+
+func TestProfileFlag(t *testing.T) {
+	p, err := profileFlag("")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultProfile, p)
+
+	for _, bad := range []string{"../etc", `a\b`, ".hidden"} {
+		_, err = profileFlag(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/joescharf/cognito"
+)
+
+// configFlags are the flags every command takes to build the AppClient. Settings are loaded
+// with cognito.LoadConfig from the config file, then environment variables, then flags,
+// later ones win.
+type configFlags struct {
+	file   string
+	output string
+	flags  cognito.AppClientConfig
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	cf := &configFlags{}
+	fs.StringVar(&cf.file, "config", "", "JSON or YAML config file with the fields of AppClientConfig (env COGNITO_CONFIG)")
+	fs.StringVar(&cf.flags.Region, "region", "", "AWS region of the user pool (env COGNITO_REGION, AWS_REGION)")
+	fs.StringVar(&cf.flags.PoolID, "pool-id", "", "user pool id (env COGNITO_POOL_ID)")
+	fs.StringVar(&cf.flags.ClientID, "client-id", "", "app client id (env COGNITO_CLIENT_ID)")
+	fs.StringVar(&cf.flags.ClientSecret, "client-secret", "", "app client secret or ssm:/secretsmanager: reference (env COGNITO_CLIENT_SECRET)")
+	fs.StringVar(&cf.flags.Domain, "domain", "", "hosted UI domain prefix (env COGNITO_DOMAIN)")
+	fs.StringVar(&cf.flags.RedirectURI, "redirect-uri", "", "hosted UI redirect URI (env COGNITO_REDIRECT_URI)")
+	fs.StringVar(&cf.output, "output", formatTable, "output format: table, json or csv")
+	return cf
+}
+
+// load loads the config with the flags as overrides. Commands need different fields,
+// so the config is not validated here.
+func (cf *configFlags) load(getenv func(string) string) (*cognito.AppClientConfig, error) {
+	return cognito.LoadConfig(&cognito.LoadConfigOptions{
+		File:           cf.file,
+		Getenv:         getenv,
+		Overrides:      &cf.flags,
+		SkipValidation: true,
+	})
+}
+
+// client returns an AppClient for the configured pool. An invalid config is an error, but
+// admin commands only need the pool, so they run without a client id or the pool's keys.
+func (cf *configFlags) client(needKeys bool) (*cognito.AppClient, error) {
+	if err := checkFormat(cf.output); err != nil {
+		return nil, err
+	}
+	cfg, err := cf.load(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	if err = validate(cfg, needKeys); err != nil {
+		return nil, err
+	}
+
+	// The config is valid, so NewAppClient can only fail to fetch the JWKS
+	c, err := cognito.NewAppClient(cfg)
+	if err != nil && needKeys {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validate checks the config, without requiring a client id unless needClient is set
+func validate(cfg *cognito.AppClientConfig, needClient bool) error {
+	err := cfg.Validate()
+	ce, ok := err.(*cognito.ConfigError)
+	if !ok || needClient {
+		return err
+	}
+	var errs []cognito.FieldError
+	for _, e := range ce.Errors {
+		if e.Field != "clientId" {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &cognito.ConfigError{Errors: errs}
+}
+
+// print writes the result in the selected output format
+func (cf *configFlags) print(t *table, v interface{}) error {
+	return printOutput(stdout, cf.output, t, v)
+}
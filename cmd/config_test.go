@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	f, err := ioutil.TempFile("", "cognito-config-*.json")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`{"region": "eu-west-1", "poolId": "file-pool", "clientId": "file-client", "domain": "file-domain"}`)
+	assert.Nil(t, err)
+	f.Close()
+
+	env := map[string]string{
+		"COGNITO_CONFIG":    f.Name(),
+		"COGNITO_POOL_ID":   "env-pool",
+		"COGNITO_CLIENT_ID": "env-client",
+		"AWS_REGION":        "us-west-2",
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	assert.Nil(t, fs.Parse([]string{"-client-id", "flag-client"}))
+
+	cfg, err := cf.load(func(name string) string { return env[name] })
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1", cfg.Region, "AWS_REGION only applies without a configured region")
+	assert.Equal(t, "env-pool", cfg.PoolID)
+	assert.Equal(t, "flag-client", cfg.ClientID)
+	assert.Equal(t, "file-domain", cfg.Domain)
+}
+
+func TestValidateClientID(t *testing.T) {
+	cfg := &cognito.AppClientConfig{Region: "us-east-1", PoolID: "us-east-1_Pool"}
+	assert.Nil(t, validate(cfg, false), "Admin commands need no client id")
+	assert.EqualError(t, validate(cfg, true), "invalid config: clientId: is required")
+
+	cfg.PoolID = "eu-west-1_Pool"
+	assert.EqualError(t, validate(cfg, false), "invalid config: poolId: pool eu-west-1_Pool is in eu-west-1, not in region us-east-1")
+}
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs, cf := newFlagSet("users get", "<username>")
+	pos, err := parseArgs(fs, []string{"-region", "us-east-1", "jdoe", "-output", "json"}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"jdoe"}, pos)
+	assert.Equal(t, "json", cf.output)
+	assert.Equal(t, "us-east-1", cf.flags.Region)
+
+	stderr = ioutil.Discard
+	defer func() { stderr = os.Stderr }()
+	fs, _ = newFlagSet("users get", "<username>")
+	_, err = parseArgs(fs, []string{"jdoe", "jane"}, 1)
+	assert.IsType(t, usageError(""), err)
+}
+
+func TestKeyValues(t *testing.T) {
+	m, err := keyValues([]string{"tenant=acme", "note=a=b"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"tenant": "acme", "note": "a=b"}, m)
+
+	_, err = keyValues([]string{"=x"})
+	assert.NotNil(t, err)
+}
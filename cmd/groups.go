@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// group is the output form of a group
+type group struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Precedence  *int64 `json:"precedence,omitempty"`
+	RoleArn     string `json:"roleArn,omitempty"`
+}
+
+func groupsAddUser(args []string) error {
+	fs, cf := newFlagSet("groups add-user", "<username> <group>")
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	if err = c.AddUserToGroup(pos[0], pos[1]); err != nil {
+		return err
+	}
+	return cf.print(statusTable("added to "+pos[1], pos[0]))
+}
+
+func groupsRemoveUser(args []string) error {
+	fs, cf := newFlagSet("groups remove-user", "<username> <group>")
+	pos, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	if err = c.RemoveUserFromGroup(pos[0], pos[1]); err != nil {
+		return err
+	}
+	return cf.print(statusTable("removed from "+pos[1], pos[0]))
+}
+
+func groupsList(args []string) error {
+	fs, cf := newFlagSet("groups list", "")
+	user := fs.String("user", "", "only the groups of this user")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+
+	var gts []*cognitoidentityprovider.GroupType
+	if *user != "" {
+		gts, err = c.GetUserGroups(*user)
+	} else {
+		gts, err = c.ListGroups()
+	}
+	if err != nil {
+		return err
+	}
+
+	groups := make([]group, 0, len(gts))
+	t := &table{header: []string{"NAME", "PRECEDENCE", "DESCRIPTION"}}
+	for _, gt := range gts {
+		g := group{
+			Name:        aws.StringValue(gt.GroupName),
+			Description: aws.StringValue(gt.Description),
+			Precedence:  gt.Precedence,
+			RoleArn:     aws.StringValue(gt.RoleArn),
+		}
+		groups = append(groups, g)
+		precedence := ""
+		if g.Precedence != nil {
+			precedence = strconv.FormatInt(*g.Precedence, 10)
+		}
+		t.rows = append(t.rows, []string{g.Name, precedence, g.Description})
+	}
+	return cf.print(t, groups)
+}
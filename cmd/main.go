@@ -1,6 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
 
 var (
 	version = "dev"
@@ -8,6 +15,166 @@ var (
 	date    = "unknown"
 )
 
+// stdout and stderr are replaced in tests
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// commands maps "<group> <command>" to its implementation, each command parses its own flags.
+// The command "" runs when the group is used without a command.
+var commands = map[string]map[string]func(args []string) error{
+	"users": {
+		"create":       usersCreate,
+		"delete":       usersDelete,
+		"confirm":      usersConfirm,
+		"set-password": usersSetPassword,
+		"enable":       usersEnable,
+		"disable":      usersDisable,
+		"get":          usersGet,
+		"list":         usersList,
+	},
+	"groups": {
+		"add-user":    groupsAddUser,
+		"remove-user": groupsRemoveUser,
+		"list":        groupsList,
+	},
+	"auth": {
+		"login": authLogin,
+	},
+	"token": {
+		"":       tokenPrint,
+		"verify": tokenVerify,
+		"decode": tokenDecode,
+		"jwks":   tokenJWKS,
+	},
+}
+
 func main() {
-	fmt.Printf("libcognito %v, commit %v, built at %v\n", version, commit, date)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command in args and returns the exit code
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	if args[0] == "version" {
+		fmt.Fprintf(stdout, "libcognito %v, commit %v, built at %v\n", version, commit, date)
+		return 0
+	}
+
+	group, ok := commands[args[0]]
+	if !ok {
+		usage(stderr)
+		return 2
+	}
+	name, rest := "", args[1:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		name, rest = rest[0], rest[1:]
+	}
+	cmd, ok := group[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", strings.TrimSpace(args[0]+" "+name))
+		usage(stderr)
+		return 2
+	}
+
+	if err := cmd(rest); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: cognito <group> <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	var names []string
+	for g, cmds := range commands {
+		for c := range cmds {
+			names = append(names, strings.TrimSpace(g+" "+c))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+name)
+	}
+	fmt.Fprintln(w, "  version")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run cognito <group> <command> -h for the flags of a command.")
+}
+
+// usageError is returned for wrong arguments, it exits with status 2
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// newFlagSet returns a flag set for the command with the config and output flags
+func newFlagSet(name, args string) (*flag.FlagSet, *configFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cognito %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs, addConfigFlags(fs)
+}
+
+// parseArgs parses flags that may come before, between or after the positional arguments
+// and checks that there are n positional arguments, or any number if n is negative
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if n >= 0 && len(positional) != n {
+		fs.Usage()
+		return nil, usageError(fmt.Sprintf("%s takes %d argument(s), got %d", fs.Name(), n, len(positional)))
+	}
+	return positional, nil
+}
+
+// stringsFlag collects a repeatable flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// keyValues parses name=value pairs
+func keyValues(pairs []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, p := range pairs {
+		i := strings.Index(p, "=")
+		if i <= 0 {
+			return nil, usageError(fmt.Sprintf("%q is not name=value", p))
+		}
+		m[p[:i]] = p[i+1:]
+	}
+	return m, nil
 }
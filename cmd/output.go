@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// table is the tabular form of a result, used for table and CSV output
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return nil
+	}
+	return usageError(fmt.Sprintf("unknown output format %q, use table, json or csv", format))
+}
+
+// printOutput writes v as JSON or t as a table or CSV
+func printOutput(w io.Writer, format string, t *table, v interface{}) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.header); err != nil {
+			return err
+		}
+		return cw.WriteAll(t.rows)
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return checkFormat(format)
+}
+
+// mapTable returns a NAME/VALUE table of m, sorted by name
+func mapTable(m map[string]interface{}) *table {
+	t := &table{header: []string{"NAME", "VALUE"}}
+	for k, v := range m {
+		t.rows = append(t.rows, []string{k, formatValue(v)})
+	}
+	sort.Slice(t.rows, func(i, j int) bool { return t.rows[i][0] < t.rows[j][0] })
+	return t
+}
+
+// formatValue formats a JSON value for a table cell
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		// JSON numbers, avoid exponents for timestamps
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = formatValue(p)
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// statusTable is the output of commands that only change something
+func statusTable(action, name string) (*table, interface{}) {
+	return &table{header: []string{"ACTION", "NAME"}, rows: [][]string{{action, name}}},
+		map[string]string{"action": action, "name": name}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintOutput(t *testing.T) {
+	tbl := &table{header: []string{"NAME", "VALUE"}, rows: [][]string{{"a", "1"}, {"long name", "x,y"}}}
+	v := map[string]string{"a": "1"}
+
+	var buf bytes.Buffer
+	assert.Nil(t, printOutput(&buf, formatTable, tbl, v))
+	assert.Equal(t, "NAME       VALUE\na          1\nlong name  x,y\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, printOutput(&buf, formatCSV, tbl, v))
+	assert.Equal(t, "NAME,VALUE\na,1\nlong name,\"x,y\"\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, printOutput(&buf, formatJSON, tbl, v))
+	assert.Equal(t, "{\n  \"a\": \"1\"\n}\n", buf.String())
+
+	assert.NotNil(t, printOutput(&buf, "yaml", tbl, v))
+}
+
+func TestMapTable(t *testing.T) {
+	tbl := mapTable(map[string]interface{}{
+		"groups": []interface{}{"admins", "users"},
+		"exp":    float64(1600000000),
+		"email":  "jdoe@example.com",
+	})
+	assert.Equal(t, [][]string{
+		{"email", "jdoe@example.com"},
+		{"exp", "1600000000"},
+		{"groups", "admins,users"},
+	}, tbl.rows)
+}
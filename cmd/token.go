@@ -0,0 +1,207 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joescharf/cognito"
+)
+
+// decodedToken is the unverified header and claims of a JWT
+type decodedToken struct {
+	Header map[string]interface{} `json:"header"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// decodeJWT decodes the header and claims of a JWT without verifying it
+func decodeJWT(t string) (*decodedToken, error) {
+	parts := strings.Split(strings.TrimSpace(t), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT, it needs three dot separated parts")
+	}
+	d := &decodedToken{}
+	if err := decodeSegment(parts[0], &d.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if err := decodeSegment(parts[1], &d.Claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %v", err)
+	}
+	return d, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := b64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// decodedTable shows the header fields prefixed with "header:" and the claims
+func decodedTable(d *decodedToken) *table {
+	m := map[string]interface{}{}
+	for k, v := range d.Header {
+		m["header:"+k] = v
+	}
+	for k, v := range d.Claims {
+		m[k] = v
+	}
+	return mapTable(m)
+}
+
+func tokenDecode(args []string) error {
+	fs, cf := newFlagSet("token decode", "<token>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err = checkFormat(cf.output); err != nil {
+		return err
+	}
+	d, err := decodeJWT(pos[0])
+	if err != nil {
+		return err
+	}
+	return cf.print(decodedTable(d), d)
+}
+
+// poolURLs returns the issuer and JWKS URL of the configured pool, empty without a pool
+func poolURLs(cfg *cognito.AppClientConfig) (issuer, jwksURL string) {
+	if cfg.Region == "" || cfg.PoolID == "" {
+		return "", ""
+	}
+	issuer = cognito.PoolConfig{Region: cfg.Region, UserPoolID: cfg.PoolID}.Issuer()
+	return issuer, issuer + "/.well-known/jwks.json"
+}
+
+func tokenVerify(args []string) error {
+	fs, cf := newFlagSet("token verify", "<token>")
+	jwksFile := fs.String("jwks", "", "verify offline with this saved JWKS file, see token jwks")
+	tokenUse := fs.String("token-use", "", "expected token_use, id or access")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err = checkFormat(cf.output); err != nil {
+		return err
+	}
+	cfg, err := cf.load(os.Getenv)
+	if err != nil {
+		return err
+	}
+	issuer, jwksURL := poolURLs(cfg)
+	if *jwksFile == "" && jwksURL == "" {
+		return usageError("need -jwks or a configured region and pool id to get the keys")
+	}
+	keys, err := loadJWKS(*jwksFile, jwksURL)
+	if err != nil {
+		return fmt.Errorf("could not load JWKS: %v", err)
+	}
+
+	r, err := verifyToken(pos[0], &verifyOptions{
+		keys:     keys,
+		issuer:   issuer,
+		clientID: cfg.ClientID,
+		tokenUse: *tokenUse,
+		now:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	t := &table{header: []string{"CHECK", "RESULT", "DETAIL"}}
+	for _, c := range r.Checks {
+		t.rows = append(t.rows, []string{c.Name, c.Result, c.Detail})
+	}
+	if cf.output == formatTable {
+		// Show what was verified above the checks
+		if err = cf.print(decodedTable(&r.decodedToken), nil); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout)
+	}
+	if err = cf.print(t, r); err != nil {
+		return err
+	}
+	if !r.Valid {
+		return errors.New("token failed verification")
+	}
+	return nil
+}
+
+func tokenJWKS(args []string) error {
+	fs, cf := newFlagSet("token jwks", "")
+	out := fs.String("out", "", "file to save the JWKS to, for token verify -jwks, stdout if empty")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	cfg, err := cf.load(os.Getenv)
+	if err != nil {
+		return err
+	}
+	_, jwksURL := poolURLs(cfg)
+	if jwksURL == "" {
+		return usageError("region and pool id are required")
+	}
+	b, err := fetchJWKS(jwksURL)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(*out, b, 0644)
+}
+
+func tokenPrint(args []string) error {
+	fs, cf := newFlagSet("token", "")
+	profileName := fs.String("profile", "", "token cache profile (env COGNITO_PROFILE, default \"default\")")
+	id := fs.Bool("id", false, "print the ID token instead of the access token")
+	minValid := fs.Duration("min-valid", time.Minute, "refresh the tokens if they expire sooner than this")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	profile, err := profileFlag(*profileName)
+	if err != nil {
+		return err
+	}
+	ct, err := loadCache(profile)
+	if err != nil {
+		return err
+	}
+
+	if time.Until(ct.Expiry) < *minValid {
+		if ct.RefreshToken == "" {
+			return errNotLoggedIn
+		}
+		// The cached tokens belong to the pool and client they were issued by,
+		// the configuration only adds the client secret
+		cf.flags.Region, cf.flags.PoolID, cf.flags.ClientID = ct.Region, ct.PoolID, ct.ClientID
+		c, err := cf.client(false)
+		if err != nil {
+			return err
+		}
+		r, err := c.RefreshAuth(ct.Username, ct.RefreshToken)
+		if err != nil {
+			return fmt.Errorf("could not refresh tokens, run cognito auth login: %v", err)
+		}
+		ct = newCachedToken(r.Token, ct.Username, c, time.Now())
+		if err = saveCache(profile, ct); err != nil {
+			return err
+		}
+	}
+
+	if *id {
+		fmt.Fprintln(stdout, ct.IDToken)
+	} else {
+		fmt.Fprintln(stdout, ct.AccessToken)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+func TestDecodeJWT(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	idToken, err := issuer.MintIDToken(cognitotest.TokenOptions{Username: "jdoe"})
+	assert.Nil(t, err)
+
+	d, err := decodeJWT(idToken + "\n")
+	assert.Nil(t, err)
+	assert.Equal(t, issuer.KeyID, d.Header["kid"])
+	assert.Equal(t, "jdoe", d.Claims["cognito:username"])
+
+	_, err = decodeJWT("not-a-token")
+	assert.NotNil(t, err)
+}
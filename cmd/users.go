@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/joescharf/cognito"
+)
+
+// usersTable lists users with their email and sub
+func usersTable(users []*cognito.User) *table {
+	t := &table{header: []string{"USERNAME", "STATUS", "ENABLED", "EMAIL", "SUB", "CREATED"}}
+	for _, u := range users {
+		t.rows = append(t.rows, []string{
+			u.Username,
+			string(u.Status),
+			strconv.FormatBool(u.Enabled),
+			u.Attribute("email"),
+			u.Sub(),
+			formatTime(u.CreatedAt),
+		})
+	}
+	return t
+}
+
+func usersCreate(args []string) error {
+	fs, cf := newFlagSet("users create", "<username>")
+	opts := &cognito.CreateUserOptions{}
+	var attrs, mediums stringsFlag
+	suppress := fs.Bool("suppress", false, "do not send the invitation message")
+	fs.StringVar(&opts.TemporaryPassword, "temp-password", "", "temporary password, generated by Cognito if empty")
+	fs.StringVar(&opts.Email, "email", "", "email address")
+	fs.StringVar(&opts.PhoneNumber, "phone", "", "phone number in E.164 format")
+	fs.BoolVar(&opts.EmailVerified, "email-verified", false, "mark the email address as verified")
+	fs.BoolVar(&opts.PhoneNumberVerified, "phone-verified", false, "mark the phone number as verified")
+	fs.Var(&attrs, "attr", "attribute as name=value, repeatable")
+	fs.Var(&mediums, "deliver", "invitation delivery medium EMAIL or SMS, repeatable")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if opts.Attributes, err = keyValues(attrs); err != nil {
+		return err
+	}
+	opts.Username = pos[0]
+	opts.DesiredDeliveryMediums = mediums
+	if *suppress {
+		opts.MessageAction = cognito.MessageActionSuppress
+	}
+
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	u, err := c.CreateUser(opts)
+	if err != nil {
+		return err
+	}
+	return cf.print(usersTable([]*cognito.User{u}), u)
+}
+
+func usersDelete(args []string) error {
+	return userAction(args, "users delete", "deleted", func(c *cognito.AppClient, username string) error {
+		return c.DeleteUser(username)
+	})
+}
+
+func usersConfirm(args []string) error {
+	return userAction(args, "users confirm", "confirmed", func(c *cognito.AppClient, username string) error {
+		return c.ConfirmUser(username)
+	})
+}
+
+func usersEnable(args []string) error {
+	return userAction(args, "users enable", "enabled", func(c *cognito.AppClient, username string) error {
+		return c.AdminEnableUser(username)
+	})
+}
+
+func usersDisable(args []string) error {
+	fs, cf := newFlagSet("users disable", "<username>")
+	signOut := fs.Bool("sign-out", false, "also revoke the user's refresh tokens on all devices")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	if err = c.AdminDisableUser(pos[0], *signOut); err != nil {
+		return err
+	}
+	return cf.print(statusTable("disabled", pos[0]))
+}
+
+// userAction runs a command that takes only a username
+func userAction(args []string, name, action string, fn func(c *cognito.AppClient, username string) error) error {
+	fs, cf := newFlagSet(name, "<username>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	if err = fn(c, pos[0]); err != nil {
+		return err
+	}
+	return cf.print(statusTable(action, pos[0]))
+}
+
+func usersSetPassword(args []string) error {
+	fs, cf := newFlagSet("users set-password", "<username>")
+	password := fs.String("password", "", "new password (env COGNITO_PASSWORD)")
+	permanent := fs.Bool("permanent", false, "set a permanent password instead of a temporary one")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *password == "" {
+		*password = os.Getenv("COGNITO_PASSWORD")
+	}
+	if *password == "" {
+		return errors.New("password is required, use -password or COGNITO_PASSWORD")
+	}
+
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	if err = c.SetUserPassword(pos[0], *password, *permanent); err != nil {
+		return err
+	}
+	return cf.print(statusTable("password set", pos[0]))
+}
+
+func usersGet(args []string) error {
+	fs, cf := newFlagSet("users get", "<username>")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	u, err := c.AdminGetUser(pos[0])
+	if err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{
+		"username": u.Username,
+		"status":   string(u.Status),
+		"enabled":  u.Enabled,
+		"created":  formatTime(u.CreatedAt),
+		"modified": formatTime(u.ModifiedAt),
+	}
+	for name, value := range u.Attributes {
+		m["attr:"+name] = value
+	}
+	return cf.print(mapTable(m), u)
+}
+
+func usersList(args []string) error {
+	fs, cf := newFlagSet("users list", "")
+	opts := &cognito.ListUsersOptions{}
+	var attrs stringsFlag
+	fs.StringVar(&opts.Filter, "filter", "", `Cognito filter expression, e.g. 'email ^= "jdoe"'`)
+	email := fs.String("email", "", "only users whose email starts with this")
+	status := fs.String("status", "", "only users with this status, e.g. CONFIRMED")
+	fs.IntVar(&opts.Limit, "limit", 0, "list at most this many users")
+	fs.Var(&attrs, "attr", "attribute to return, repeatable")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	// Cognito accepts a single filter expression
+	switch {
+	case *email != "" && (opts.Filter != "" || *status != ""), *status != "" && opts.Filter != "":
+		return usageError("use only one of -filter, -email and -status")
+	case *email != "":
+		opts.Filter = cognito.UserFilter("email", *email, true)
+	case *status != "":
+		opts.Filter = cognito.UserFilter("cognito:user_status", *status, false)
+	}
+	opts.Attributes = attrs
+
+	c, err := cf.client(false)
+	if err != nil {
+		return err
+	}
+	users, err := c.ListUsersFiltered(opts)
+	if err != nil {
+		return err
+	}
+	return cf.print(usersTable(users), users)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwk"
+
+	"github.com/joescharf/cognito"
+)
+
+// Results of a check
+const (
+	checkPass = "pass"
+	checkFail = "FAIL"
+	checkSkip = "skip"
+)
+
+// check is the result of one verification step
+type check struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Detail string `json:"detail"`
+}
+
+// verifyReport is the output of token verify
+type verifyReport struct {
+	decodedToken
+	Checks    []check `json:"checks"`
+	Valid     bool    `json:"valid"`
+	ExpiresIn string  `json:"expiresIn,omitempty"`
+}
+
+// verifyOptions are the expectations a token is checked against, empty ones are skipped
+type verifyOptions struct {
+	keys     *jwk.Set
+	issuer   string
+	clientID string
+	tokenUse string
+	now      time.Time
+}
+
+// verifyToken runs the checks of ParseAndVerifyJWT one by one, so all failures are reported
+func verifyToken(t string, opts *verifyOptions) (*verifyReport, error) {
+	d, err := decodeJWT(t)
+	if err != nil {
+		return nil, err
+	}
+	r := &verifyReport{decodedToken: *d}
+	add := func(name, result, detail string, args ...interface{}) {
+		r.Checks = append(r.Checks, check{Name: name, Result: result, Detail: fmt.Sprintf(detail, args...)})
+	}
+	claims := jwt.MapClaims(d.Claims)
+	now := opts.now.Unix()
+
+	if err = verifySignature(t, opts.keys); err != nil {
+		add("signature", checkFail, "%v", err)
+	} else {
+		add("signature", checkPass, "%v signed with kid %v", d.Header["alg"], d.Header["kid"])
+	}
+
+	if exp, ok := numericClaim(claims, "exp"); !ok {
+		add("exp", checkFail, "no exp claim")
+	} else {
+		left := time.Unix(exp, 0).Sub(opts.now)
+		if claims.VerifyExpiresAt(now, true) {
+			r.ExpiresIn = humanDuration(left)
+			add("exp", checkPass, "expires in %s (%s)", r.ExpiresIn, formatTime(time.Unix(exp, 0)))
+		} else {
+			add("exp", checkFail, "expired %s ago (%s)", humanDuration(-left), formatTime(time.Unix(exp, 0)))
+		}
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); !ok {
+		add("nbf", checkSkip, "no nbf claim")
+	} else if claims.VerifyNotBefore(now, true) {
+		add("nbf", checkPass, "valid since %s", formatTime(time.Unix(nbf, 0)))
+	} else {
+		add("nbf", checkFail, "not valid before %s", formatTime(time.Unix(nbf, 0)))
+	}
+
+	if iat, ok := numericClaim(claims, "iat"); !ok {
+		add("iat", checkFail, "no iat claim")
+	} else if claims.VerifyIssuedAt(now, true) {
+		add("iat", checkPass, "issued %s ago", humanDuration(opts.now.Sub(time.Unix(iat, 0))))
+	} else {
+		add("iat", checkFail, "issued in the future (%s), check the clock", formatTime(time.Unix(iat, 0)))
+	}
+
+	// ID tokens carry the client in aud, access tokens in client_id
+	tokenUse, _ := claims["token_use"].(string)
+	clientClaim := "aud"
+	if tokenUse == "access" {
+		clientClaim = "client_id"
+	}
+	client, _ := claims[clientClaim].(string)
+	switch {
+	case opts.clientID == "":
+		add(clientClaim, checkSkip, "%q, no client id configured", client)
+	case client == opts.clientID:
+		add(clientClaim, checkPass, "%q", client)
+	default:
+		add(clientClaim, checkFail, "%q, expected %q", client, opts.clientID)
+	}
+
+	iss, _ := claims["iss"].(string)
+	switch {
+	case opts.issuer == "":
+		add("iss", checkSkip, "%q, no pool configured", iss)
+	case iss == opts.issuer:
+		add("iss", checkPass, "%q", iss)
+	default:
+		add("iss", checkFail, "%q, expected %q", iss, opts.issuer)
+	}
+
+	switch {
+	case opts.tokenUse != "" && tokenUse != opts.tokenUse:
+		add("token_use", checkFail, "%q, expected %q", tokenUse, opts.tokenUse)
+	case tokenUse == "id" || tokenUse == "access":
+		add("token_use", checkPass, "%q", tokenUse)
+	default:
+		add("token_use", checkFail, "%q is neither id nor access", tokenUse)
+	}
+
+	r.Valid = true
+	for _, c := range r.Checks {
+		if c.Result == checkFail {
+			r.Valid = false
+		}
+	}
+	return r, nil
+}
+
+// verifySignature verifies the signature with the key lookup of ParseAndVerifyJWT, the
+// claims are checked one by one by verifyToken
+func verifySignature(t string, keys *jwk.Set) error {
+	if keys == nil {
+		return errors.New("no JWKS, see -jwks")
+	}
+	p := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := p.Parse(t, cognito.KeyFunc(keys))
+	return err
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	v, ok := claims[name].(float64)
+	return int64(v), ok
+}
+
+// humanDuration formats d rounded to seconds, with days for long durations
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 48*time.Hour {
+		return d.String()
+	}
+	days := d / (24 * time.Hour)
+	return fmt.Sprintf("%dd%s", days, (d - days*24*time.Hour).String())
+}
+
+// loadJWKS reads a saved JWKS file, or fetches the JWKS of the pool if file is empty
+func loadJWKS(file, jwksURL string) (*jwk.Set, error) {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return jwk.ParseBytes(b)
+	}
+	if jwksURL == "" {
+		return nil, nil
+	}
+	return jwk.Fetch(jwksURL)
+}
+
+// fetchJWKS returns the raw JWKS document of the pool, for saving it
+func fetchJWKS(jwksURL string) ([]byte, error) {
+	resp, err := http.Get(jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", jwksURL, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure a valid JWKS is saved
+	if _, err = jwk.ParseBytes(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+// results maps check names to their results
+func results(r *verifyReport) map[string]string {
+	m := map[string]string{}
+	for _, c := range r.Checks {
+		m[c.Name] = c.Result
+	}
+	return m
+}
+
+func TestVerifyToken(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	opts := &verifyOptions{
+		keys:     issuer.KeySet(),
+		issuer:   issuer.IssuerURL(),
+		clientID: issuer.ClientID,
+		now:      time.Now().Truncate(time.Second),
+	}
+
+	idToken, _ := issuer.MintIDToken(cognitotest.TokenOptions{ExpiresAt: opts.now.Add(90 * time.Minute)})
+	r, err := verifyToken(idToken, opts)
+	assert.Nil(t, err)
+	assert.True(t, r.Valid, "%v", r.Checks)
+	assert.Equal(t, map[string]string{
+		"signature": checkPass, "exp": checkPass, "nbf": checkSkip, "iat": checkPass,
+		"aud": checkPass, "iss": checkPass, "token_use": checkPass,
+	}, results(r))
+	assert.Equal(t, "1h30m0s", r.ExpiresIn)
+
+	// Access tokens carry the client in client_id
+	accessToken, _ := issuer.MintAccessToken(cognitotest.TokenOptions{})
+	opts.tokenUse = "id"
+	r, err = verifyToken(accessToken, opts)
+	assert.Nil(t, err)
+	assert.False(t, r.Valid)
+	assert.Equal(t, checkPass, results(r)["client_id"])
+	assert.Equal(t, checkFail, results(r)["token_use"])
+
+	// Every failing check is reported, not only the first
+	other := cognitotest.MustNewIssuer("us-west-2", "us-west-2_Other", "other-client")
+	expired, _ := other.MintIDToken(cognitotest.TokenOptions{
+		IssuedAt:  opts.now.Add(-2 * time.Hour),
+		ExpiresAt: opts.now.Add(-time.Hour),
+	})
+	opts.tokenUse = ""
+	r, err = verifyToken(expired, opts)
+	assert.Nil(t, err)
+	assert.False(t, r.Valid)
+	m := results(r)
+	for _, name := range []string{"signature", "exp", "aud", "iss"} {
+		assert.Equal(t, checkFail, m[name], name)
+	}
+}
+
+func TestTokenVerifyOffline(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	jwks, err := json.Marshal(issuer.KeySet())
+	assert.Nil(t, err)
+	f, err := ioutil.TempFile("", "jwks-*.json")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.Write(jwks)
+	f.Close()
+
+	var out bytes.Buffer
+	stdout, stderr = &out, ioutil.Discard
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	idToken, _ := issuer.MintIDToken(cognitotest.TokenOptions{})
+	code := run([]string{"token", "verify", "-jwks", f.Name(), "-output", "json", idToken})
+	assert.Equal(t, 0, code)
+	var r verifyReport
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &r))
+	assert.True(t, r.Valid)
+
+	other := cognitotest.MustNewIssuer("", "", "")
+	forged, _ := other.MintIDToken(cognitotest.TokenOptions{})
+	assert.Equal(t, 1, run([]string{"token", "verify", "-jwks", f.Name(), forged}))
+}
+
+func TestHumanDuration(t *testing.T) {
+	assert.Equal(t, "59m59s", humanDuration(time.Hour-time.Second+time.Millisecond*400))
+	assert.Equal(t, "3d2h0m0s", humanDuration(74*time.Hour))
+}
@@ -0,0 +1,221 @@
+// Package cognitotest provides utilities for testing code that verifies
+// Cognito issued JSON web tokens without access to AWS.
+//
+// An Issuer generates an RSA keypair, exposes the public half as a jwk.Set
+// that can be loaded into a cognito.AppClient, and mints ID and access tokens
+// that look like the ones issued by a Cognito user pool.
+package cognitotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwk"
+
+	"github.com/joescharf/cognito"
+)
+
+// Default values used by NewIssuer when none are given
+const (
+	DefaultRegion   = "us-east-1"
+	DefaultPoolID   = "us-east-1_TestPool"
+	DefaultClientID = "testclientid"
+	DefaultKeyID    = "cognitotest-key"
+)
+
+// Issuer mints tokens signed with its own RSA key, mimicking a Cognito user pool
+type Issuer struct {
+	Region     string
+	UserPoolID string
+	ClientID   string
+	KeyID      string
+
+	key *rsa.PrivateKey
+	set *jwk.Set
+}
+
+// TokenOptions controls the claims of a minted token.
+// Zero values are replaced with sensible defaults.
+type TokenOptions struct {
+	// Subject is the `sub` claim, defaults to a fixed test UUID
+	Subject string
+	// Username is `cognito:username` on ID tokens and `username` on access tokens
+	Username string
+	// Email is only set on ID tokens
+	Email string
+	// Groups is the `cognito:groups` claim
+	Groups []string
+	// Scope is the `scope` claim of access tokens, defaults to "aws.cognito.signin.user.admin"
+	Scope string
+	// IssuedAt is the `iat` and `auth_time` claim, defaults to now
+	IssuedAt time.Time
+	// ExpiresAt is the `exp` claim, defaults to IssuedAt plus one hour
+	ExpiresAt time.Time
+	// KeyID overrides the `kid` header, e.g. to test unknown keys
+	KeyID string
+	// Claims are merged last and override any of the claims above
+	Claims map[string]interface{}
+}
+
+// NewIssuer returns an Issuer with a freshly generated 2048 bit RSA key.
+// Empty arguments are replaced with the package defaults.
+func NewIssuer(region, poolID, clientID string) (*Issuer, error) {
+	if region == "" {
+		region = DefaultRegion
+	}
+	if poolID == "" {
+		poolID = DefaultPoolID
+	}
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := jwk.New(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = pub.Set(jwk.KeyIDKey, DefaultKeyID); err != nil {
+		return nil, err
+	}
+	if err = pub.Set(jwk.AlgorithmKey, "RS256"); err != nil {
+		return nil, err
+	}
+	if err = pub.Set(jwk.KeyUsageKey, string(jwk.ForSignature)); err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		Region:     region,
+		UserPoolID: poolID,
+		ClientID:   clientID,
+		KeyID:      DefaultKeyID,
+		key:        key,
+		set:        &jwk.Set{Keys: []jwk.Key{pub}},
+	}, nil
+}
+
+// MustNewIssuer is like NewIssuer but panics on error, for use in test setup
+func MustNewIssuer(region, poolID, clientID string) *Issuer {
+	i, err := NewIssuer(region, poolID, clientID)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// KeySet returns the public JSON web key set of the issuer
+func (i *Issuer) KeySet() *jwk.Set {
+	return i.set
+}
+
+// PrivateKey returns the signing key of the issuer
+func (i *Issuer) PrivateKey() *rsa.PrivateKey {
+	return i.key
+}
+
+// IssuerURL returns the `iss` claim of minted tokens:
+// https://cognito-idp.<region>.amazonaws.com/<pool_id>
+func (i *Issuer) IssuerURL() string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", i.Region, i.UserPoolID)
+}
+
+// Configure points an existing AppClient at this issuer
+func (i *Issuer) Configure(c *cognito.AppClient) {
+	c.Region = i.Region
+	c.UserPoolID = i.UserPoolID
+	c.ClientID = i.ClientID
+	c.WellKnownJWKs = i.set
+}
+
+// NewAppClient returns an AppClient that verifies tokens minted by this issuer.
+// No AWS calls are made.
+func (i *Issuer) NewAppClient() *cognito.AppClient {
+	c := &cognito.AppClient{}
+	i.Configure(c)
+	return c
+}
+
+// MintIDToken returns a signed Cognito style ID token
+func (i *Issuer) MintIDToken(opts TokenOptions) (string, error) {
+	claims := i.baseClaims("id", &opts)
+	claims["aud"] = i.ClientID
+	claims["cognito:username"] = opts.Username
+	claims["email_verified"] = opts.Email != ""
+	if opts.Email != "" {
+		claims["email"] = opts.Email
+	}
+	return i.sign(claims, opts)
+}
+
+// MintAccessToken returns a signed Cognito style access token
+func (i *Issuer) MintAccessToken(opts TokenOptions) (string, error) {
+	claims := i.baseClaims("access", &opts)
+	claims["client_id"] = i.ClientID
+	claims["username"] = opts.Username
+	claims["jti"] = "00000000-0000-4000-8000-000000000002"
+	if opts.Scope == "" {
+		opts.Scope = "aws.cognito.signin.user.admin"
+	}
+	claims["scope"] = opts.Scope
+	return i.sign(claims, opts)
+}
+
+// Mint returns a token signed by the issuer containing exactly the given claims.
+// An empty kid uses the issuer's key id.
+func (i *Issuer) Mint(claims jwt.MapClaims, kid string) (string, error) {
+	if i.key == nil {
+		return "", errors.New("cognitotest: issuer has no key, use NewIssuer")
+	}
+	if kid == "" {
+		kid = i.KeyID
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(i.key)
+}
+
+// baseClaims sets the claims shared by ID and access tokens and fills in option defaults
+func (i *Issuer) baseClaims(use string, opts *TokenOptions) jwt.MapClaims {
+	if opts.Subject == "" {
+		opts.Subject = "00000000-0000-4000-8000-000000000001"
+	}
+	if opts.Username == "" {
+		opts.Username = opts.Subject
+	}
+	if opts.IssuedAt.IsZero() {
+		opts.IssuedAt = time.Now()
+	}
+	if opts.ExpiresAt.IsZero() {
+		opts.ExpiresAt = opts.IssuedAt.Add(time.Hour)
+	}
+
+	claims := jwt.MapClaims{
+		"sub":       opts.Subject,
+		"iss":       i.IssuerURL(),
+		"token_use": use,
+		"auth_time": opts.IssuedAt.Unix(),
+		"iat":       opts.IssuedAt.Unix(),
+		"exp":       opts.ExpiresAt.Unix(),
+		"event_id":  "00000000-0000-4000-8000-000000000003",
+	}
+	if len(opts.Groups) > 0 {
+		claims["cognito:groups"] = opts.Groups
+	}
+	return claims
+}
+
+func (i *Issuer) sign(claims jwt.MapClaims, opts TokenOptions) (string, error) {
+	for k, v := range opts.Claims {
+		claims[k] = v
+	}
+	return i.Mint(claims, opts.KeyID)
+}
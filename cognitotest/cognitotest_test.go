@@ -0,0 +1,75 @@
+package cognitotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMintedTokensVerify(t *testing.T) {
+	issuer := MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+
+	tests := []struct {
+		name  string
+		mint  func() (string, error)
+		valid bool
+	}{
+		{"id token", func() (string, error) {
+			return issuer.MintIDToken(TokenOptions{Email: "user@example.com", Groups: []string{"admins"}})
+		}, true},
+		{"access token", func() (string, error) {
+			return issuer.MintAccessToken(TokenOptions{})
+		}, true},
+		{"expired", func() (string, error) {
+			return issuer.MintIDToken(TokenOptions{ExpiresAt: time.Now().Add(-time.Minute)})
+		}, false},
+		{"wrong audience", func() (string, error) {
+			return issuer.MintIDToken(TokenOptions{Claims: map[string]interface{}{"aud": "other"}})
+		}, false},
+		{"unknown kid", func() (string, error) {
+			return issuer.MintIDToken(TokenOptions{KeyID: "unknown"})
+		}, false},
+		{"foreign key", func() (string, error) {
+			return MustNewIssuer("", "", "").MintIDToken(TokenOptions{})
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.mint()
+			assert.Nil(t, err, "Error minting token")
+
+			token, err := client.ParseAndVerifyJWT(raw)
+			if tt.valid {
+				assert.Nil(t, err, "Error verifying token")
+				assert.NotNil(t, token)
+			} else {
+				assert.NotNil(t, err, "Invalid token verified")
+			}
+		})
+	}
+}
+
+func TestMintIDTokenClaims(t *testing.T) {
+	issuer := MustNewIssuer("eu-west-1", "eu-west-1_abc", "client")
+	raw, err := issuer.MintIDToken(TokenOptions{
+		Subject:  "sub-1",
+		Username: "jdoe",
+		Groups:   []string{"admins", "users"},
+	})
+	assert.Nil(t, err)
+
+	token, err := issuer.NewAppClient().ParseAndVerifyJWT(raw)
+	assert.Nil(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "sub-1", claims["sub"])
+	assert.Equal(t, "jdoe", claims["cognito:username"])
+	assert.Equal(t, "id", claims["token_use"])
+	assert.Equal(t, "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_abc", claims["iss"])
+	assert.Equal(t, []interface{}{"admins", "users"}, claims["cognito:groups"])
+	assert.Equal(t, DefaultKeyID, token.Header["kid"])
+}
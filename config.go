@@ -0,0 +1,356 @@
+package cognito
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigEnvPrefix is the default prefix of the environment variables read by LoadConfig
+const ConfigEnvPrefix = "COGNITO_"
+
+// Prefixes of secret references, see LoadConfigOptions.ResolveSecret
+const (
+	SSMSecretPrefix            = "ssm:"
+	SecretsManagerSecretPrefix = "secretsmanager:"
+)
+
+// configEnv lists the settings read from environment variables, without the prefix
+var configEnv = []struct {
+	name  string
+	field func(cfg *AppClientConfig) *string
+}{
+	{"REGION", func(cfg *AppClientConfig) *string { return &cfg.Region }},
+	{"POOL_ID", func(cfg *AppClientConfig) *string { return &cfg.PoolID }},
+	{"CLIENT_ID", func(cfg *AppClientConfig) *string { return &cfg.ClientID }},
+	{"CLIENT_SECRET", func(cfg *AppClientConfig) *string { return &cfg.ClientSecret }},
+	{"DOMAIN", func(cfg *AppClientConfig) *string { return &cfg.Domain }},
+	{"REDIRECT_URI", func(cfg *AppClientConfig) *string { return &cfg.RedirectURI }},
+	{"LOGOUT_REDIRECT_URI", func(cfg *AppClientConfig) *string { return &cfg.LogoutRedirectURI }},
+	{"DEVICE_NAME", func(cfg *AppClientConfig) *string { return &cfg.DeviceName }},
+	{"AWS_PROFILE", func(cfg *AppClientConfig) *string { return &cfg.AWSProfile }},
+	{"ASSUME_ROLE_ARN", func(cfg *AppClientConfig) *string { return &cfg.AssumeRoleARN }},
+	{"EXTERNAL_ID", func(cfg *AppClientConfig) *string { return &cfg.ExternalID }},
+	{"ROLE_SESSION_NAME", func(cfg *AppClientConfig) *string { return &cfg.RoleSessionName }},
+	{"WEB_IDENTITY_TOKEN_FILE", func(cfg *AppClientConfig) *string { return &cfg.WebIdentityTokenFile }},
+}
+
+// poolIDPattern matches user pool ids like us-east-1_AbC123, the first group is the region
+var poolIDPattern = regexp.MustCompile(`^([a-z]{2}(?:-[a-z]+)+-\d+)_[0-9A-Za-z]+$`)
+
+// LoadConfigOptions controls LoadConfig
+type LoadConfigOptions struct {
+	// Defaults are the settings to start from
+	Defaults *AppClientConfig
+	// File is a JSON or, with a .yaml or .yml extension, YAML file with the JSON names of
+	// the AppClientConfig fields. It is optional, unless set it is read from <prefix>CONFIG.
+	File string
+	// EnvPrefix is the prefix of the environment variables, defaults to ConfigEnvPrefix.
+	// The variables are <prefix>REGION, <prefix>POOL_ID, <prefix>CLIENT_ID,
+	// <prefix>CLIENT_SECRET, <prefix>DOMAIN, <prefix>REDIRECT_URI, <prefix>LOGOUT_REDIRECT_URI,
+	// <prefix>DEVICE_NAME, <prefix>MAX_RETRIES and for the AWS credentials <prefix>AWS_PROFILE,
+	// <prefix>ASSUME_ROLE_ARN, <prefix>EXTERNAL_ID, <prefix>ROLE_SESSION_NAME and
+	// <prefix>WEB_IDENTITY_TOKEN_FILE. AWS_REGION is used if no region is set.
+	EnvPrefix string
+	// Getenv reads the environment variables, defaults to os.Getenv
+	Getenv func(key string) string
+	// Overrides are applied last, the fields that are set win
+	Overrides *AppClientConfig
+	// ResolveSecret returns the client secret for a reference like ssm:/myapp/client-secret
+	// or secretsmanager:myapp/cognito#clientSecret, where the optional key after # selects a
+	// field of a JSON secret. Defaults to reading SSM or Secrets Manager with the region and
+	// AWS credentials of the loaded config.
+	ResolveSecret func(ref string) (string, error)
+	// SkipValidation returns the config without validating it, for callers that need only
+	// some of the fields and check them with Validate or themselves
+	SkipValidation bool
+}
+
+// FieldError is a problem with a config field, Field is the JSON name of the field
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ConfigError lists every problem found in a config
+type ConfigError struct {
+	Errors []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// LoadConfig layers the defaults, the config file, the environment variables and the
+// overrides, later ones win, resolves a client secret reference and validates the result
+// unless SkipValidation is set.
+// A *ConfigError reports all missing or inconsistent fields at once.
+func LoadConfig(opts *LoadConfigOptions) (*AppClientConfig, error) {
+	if opts == nil {
+		opts = &LoadConfigOptions{}
+	}
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	prefix := opts.EnvPrefix
+	if prefix == "" {
+		prefix = ConfigEnvPrefix
+	}
+
+	cfg := &AppClientConfig{}
+	if opts.Defaults != nil {
+		*cfg = *opts.Defaults
+	}
+
+	file := opts.File
+	if file == "" {
+		file = getenv(prefix + "CONFIG")
+	}
+	if file != "" {
+		if err := readConfigFile(file, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range configEnv {
+		if v := getenv(prefix + e.name); v != "" {
+			*e.field(cfg) = v
+		}
+	}
+	if v := getenv(prefix + "MAX_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sMAX_RETRIES %q", prefix, v)
+		}
+		cfg.MaxRetries = n
+	}
+	if cfg.Region == "" {
+		cfg.Region = getenv("AWS_REGION")
+	}
+
+	if opts.Overrides != nil {
+		overrideConfig(cfg, opts.Overrides)
+	}
+
+	if isSecretRef(cfg.ClientSecret) {
+		resolve := opts.ResolveSecret
+		if resolve == nil {
+			resolve = awsSecretResolver(cfg)
+		}
+		secret, err := resolve(cfg.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve the client secret %s: %v", cfg.ClientSecret, err)
+		}
+		cfg.ClientSecret = secret
+	}
+
+	if opts.SkipValidation {
+		return cfg, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads a JSON or YAML config file into cfg
+func readConfigFile(file string, cfg *AppClientConfig) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		// Go through JSON, so YAML files use the same field names
+		var m map[string]interface{}
+		if err = yaml.Unmarshal(b, &m); err == nil {
+			b, err = json.Marshal(m)
+		}
+	}
+	if err == nil {
+		err = json.Unmarshal(b, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %v", file, err)
+	}
+	return nil
+}
+
+// overrideConfig copies the fields that are set in o to cfg
+func overrideConfig(cfg, o *AppClientConfig) {
+	for _, e := range configEnv {
+		if v := *e.field(o); v != "" {
+			*e.field(cfg) = v
+		}
+	}
+	if o.AWSAccessKey != "" {
+		cfg.AWSAccessKey = o.AWSAccessKey
+		cfg.AWSSecretAccessKey = o.AWSSecretAccessKey
+	}
+	if o.AWSSession != nil {
+		cfg.AWSSession = o.AWSSession
+	}
+	if o.CredentialsProvider != nil {
+		cfg.CredentialsProvider = o.CredentialsProvider
+	}
+	if o.MaxRetries != 0 {
+		cfg.MaxRetries = o.MaxRetries
+	}
+	if o.Strict {
+		cfg.Strict = true
+	}
+	if o.DeviceStore != nil {
+		cfg.DeviceStore = o.DeviceStore
+	}
+	if o.RateLimiter != nil {
+		cfg.RateLimiter = o.RateLimiter
+	}
+	if o.Hooks != nil {
+		cfg.Hooks = o.Hooks
+	}
+	if o.TraceContext != nil {
+		cfg.TraceContext = o.TraceContext
+	}
+	if o.AWSClientTracer != nil {
+		cfg.AWSClientTracer = o.AWSClientTracer
+	}
+}
+
+// Validate checks that the fields needed to talk to the user pool are present and well formed.
+// It returns a *ConfigError listing every problem, or nil.
+func (cfg *AppClientConfig) Validate() error {
+	var errs []FieldError
+	if cfg.Region == "" {
+		errs = append(errs, FieldError{"region", "is required"})
+	}
+	switch m := poolIDPattern.FindStringSubmatch(cfg.PoolID); {
+	case cfg.PoolID == "":
+		errs = append(errs, FieldError{"poolId", "is required"})
+	case m == nil:
+		errs = append(errs, FieldError{"poolId", fmt.Sprintf("%q is not a user pool id like us-east-1_AbC123", cfg.PoolID)})
+	case cfg.Region != "" && m[1] != cfg.Region:
+		errs = append(errs, FieldError{"poolId", fmt.Sprintf("pool %s is in %s, not in region %s", cfg.PoolID, m[1], cfg.Region)})
+	}
+	if cfg.ClientID == "" {
+		errs = append(errs, FieldError{"clientId", "is required"})
+	}
+	if cfg.Domain != "" && cfg.RedirectURI == "" {
+		errs = append(errs, FieldError{"redirectUri", "is required with domain"})
+	}
+	for _, f := range []struct{ field, uri string }{
+		{"redirectUri", cfg.RedirectURI},
+		{"logoutRedirectUri", cfg.LogoutRedirectURI},
+	} {
+		if u, err := url.Parse(f.uri); f.uri != "" && (err != nil || !u.IsAbs() || u.Host == "") {
+			errs = append(errs, FieldError{f.field, fmt.Sprintf("%q is not an absolute URL", f.uri)})
+		}
+	}
+	if cfg.AssumeRoleARN == "" {
+		if cfg.WebIdentityTokenFile != "" {
+			errs = append(errs, FieldError{"assumeRoleArn", "is required with webIdentityTokenFile"})
+		}
+		if cfg.ExternalID != "" {
+			errs = append(errs, FieldError{"assumeRoleArn", "is required with externalId"})
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+	return nil
+}
+
+// isSecretRef reports whether v references a secret instead of being one
+func isSecretRef(v string) bool {
+	return strings.HasPrefix(v, SSMSecretPrefix) || strings.HasPrefix(v, SecretsManagerSecretPrefix)
+}
+
+// awsSecretResolver reads secret references from SSM and Secrets Manager
+func awsSecretResolver(cfg *AppClientConfig) func(ref string) (string, error) {
+	return func(ref string) (string, error) {
+		ses, err := credentialsClient(cfg).awsSession()
+		if err != nil {
+			return "", err
+		}
+
+		if strings.HasPrefix(ref, SSMSecretPrefix) {
+			out, err := ssm.New(ses).GetParameter(&ssm.GetParameterInput{
+				Name:           aws.String(strings.TrimPrefix(ref, SSMSecretPrefix)),
+				WithDecryption: aws.Bool(true),
+			})
+			if err != nil {
+				return "", err
+			}
+			return aws.StringValue(out.Parameter.Value), nil
+		}
+
+		id, key := splitSecretKey(strings.TrimPrefix(ref, SecretsManagerSecretPrefix))
+		out, err := secretsmanager.New(ses).GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(id),
+		})
+		if err != nil {
+			return "", err
+		}
+		return secretValue(aws.StringValue(out.SecretString), key)
+	}
+}
+
+// credentialsClient returns a client with only the region and AWS credentials of cfg
+func credentialsClient(cfg *AppClientConfig) *AppClient {
+	return &AppClient{
+		Region:               cfg.Region,
+		AWSAccessKey:         cfg.AWSAccessKey,
+		AWSSecretAccessKey:   cfg.AWSSecretAccessKey,
+		AWSSession:           cfg.AWSSession,
+		AWSProfile:           cfg.AWSProfile,
+		CredentialsProvider:  cfg.CredentialsProvider,
+		AssumeRoleARN:        cfg.AssumeRoleARN,
+		ExternalID:           cfg.ExternalID,
+		RoleSessionName:      cfg.RoleSessionName,
+		WebIdentityTokenFile: cfg.WebIdentityTokenFile,
+	}
+}
+
+// splitSecretKey splits a Secrets Manager reference into the secret id and the JSON key
+func splitSecretKey(ref string) (id, key string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// secretValue returns the secret, or the field key of a JSON secret
+func secretValue(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", errors.New("secret is not a JSON object")
+	}
+	v, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no string field %q", key)
+	}
+	return v, nil
+}
@@ -0,0 +1,118 @@
+package cognito
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cognito")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := writeConfigFile(t, dir, "cognito.yaml", "region: us-east-1\npoolId: us-east-1_File\nclientId: file-client\ndomain: file\nredirectUri: https://app.example.com/callback\n")
+	env := map[string]string{
+		"COGNITO_CONFIG":    file,
+		"COGNITO_CLIENT_ID": "env-client",
+		"COGNITO_DOMAIN":    "env",
+		"MY_DOMAIN":         "ignored",
+	}
+	cfg, err := LoadConfig(&LoadConfigOptions{
+		Defaults:  &AppClientConfig{DeviceName: "default-device", Domain: "default"},
+		Getenv:    func(key string) string { return env[key] },
+		Overrides: &AppClientConfig{Domain: "override"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "us-east-1", cfg.Region)
+	assert.Equal(t, "us-east-1_File", cfg.PoolID)
+	assert.Equal(t, "env-client", cfg.ClientID)
+	assert.Equal(t, "override", cfg.Domain)
+	assert.Equal(t, "default-device", cfg.DeviceName)
+
+	// JSON files and a custom prefix
+	file = writeConfigFile(t, dir, "cognito.json", `{"region": "eu-west-1", "poolId": "eu-west-1_Json", "clientId": "json-client"}`)
+	env = map[string]string{"MY_DOMAIN": "mine", "MY_REDIRECT_URI": "https://app.example.com/callback", "MY_MAX_RETRIES": "2"}
+	cfg, err = LoadConfig(&LoadConfigOptions{File: file, EnvPrefix: "MY_", Getenv: func(key string) string { return env[key] }})
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1_Json", cfg.PoolID)
+	assert.Equal(t, "mine", cfg.Domain)
+	assert.Equal(t, 2, cfg.MaxRetries)
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	env := map[string]string{"AWS_REGION": "us-east-1", "COGNITO_POOL_ID": "eu-west-1_Pool"}
+	_, err := LoadConfig(&LoadConfigOptions{Getenv: func(key string) string { return env[key] }})
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, []FieldError{
+			{"poolId", "pool eu-west-1_Pool is in eu-west-1, not in region us-east-1"},
+			{"clientId", "is required"},
+		}, err.(*ConfigError).Errors)
+	}
+
+	_, err = LoadConfig(&LoadConfigOptions{Getenv: func(string) string { return "" }, Overrides: &AppClientConfig{PoolID: "pool"}})
+	assert.EqualError(t, err, `invalid config: region: is required; poolId: "pool" is not a user pool id like us-east-1_AbC123; clientId: is required`)
+
+	cfg, err := LoadConfig(&LoadConfigOptions{Getenv: func(string) string { return "" }, Overrides: &AppClientConfig{PoolID: "pool"}, SkipValidation: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "pool", cfg.PoolID)
+
+	assert.Nil(t, (&AppClientConfig{Region: "us-gov-west-1", PoolID: "us-gov-west-1_AbC123", ClientID: "client"}).Validate())
+	err = (&AppClientConfig{Region: "us-east-1", PoolID: "us-east-1_Pool", ClientID: "client", WebIdentityTokenFile: "/var/run/token"}).Validate()
+	assert.EqualError(t, err, "invalid config: assumeRoleArn: is required with webIdentityTokenFile")
+	err = (&AppClientConfig{Region: "us-east-1", PoolID: "us-east-1_Pool", ClientID: "client", Domain: "auth", LogoutRedirectURI: "/logout"}).Validate()
+	assert.EqualError(t, err, `invalid config: redirectUri: is required with domain; logoutRedirectUri: "/logout" is not an absolute URL`)
+}
+
+func TestLoadConfigResolvesSecret(t *testing.T) {
+	overrides := &AppClientConfig{Region: "us-east-1", PoolID: "us-east-1_Pool", ClientID: "client", ClientSecret: "ssm:/app/secret"}
+	var resolved string
+	cfg, err := LoadConfig(&LoadConfigOptions{
+		Getenv:    func(string) string { return "" },
+		Overrides: overrides,
+		ResolveSecret: func(ref string) (string, error) {
+			resolved = ref
+			return "s3cret", nil
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "ssm:/app/secret", resolved)
+	assert.Equal(t, "s3cret", cfg.ClientSecret)
+
+	overrides.ClientSecret = "secretsmanager:app"
+	_, err = LoadConfig(&LoadConfigOptions{
+		Getenv:        func(string) string { return "" },
+		Overrides:     overrides,
+		ResolveSecret: func(ref string) (string, error) { return "", errors.New("access denied") },
+	})
+	assert.EqualError(t, err, "could not resolve the client secret secretsmanager:app: access denied")
+}
+
+func TestSecretsManagerKeys(t *testing.T) {
+	id, key := splitSecretKey("arn:aws:secretsmanager:us-east-1:123:secret:app#clientSecret")
+	assert.Equal(t, "arn:aws:secretsmanager:us-east-1:123:secret:app", id)
+	assert.Equal(t, "clientSecret", key)
+
+	v, err := secretValue(`{"clientSecret": "s3cret"}`, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", v)
+	_, err = secretValue(`{"other": "s3cret"}`, key)
+	assert.EqualError(t, err, `secret has no string field "clientSecret"`)
+	v, err = secretValue("plain", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", v)
+}
@@ -0,0 +1,102 @@
+package cognito
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// CustomAuthOptions starts a CUSTOM_AUTH flow driven by the Define, Create and Verify
+// Auth Challenge Lambda triggers, e.g. for magic link or one time code login
+type CustomAuthOptions struct {
+	// Username is required
+	Username string
+	// Password, if set, is verified with SRP before the first custom challenge.
+	// The Define Auth Challenge trigger must issue PASSWORD_VERIFIER for this.
+	Password string
+	// ClientMetadata is passed to the Lambda triggers on every round
+	ClientMetadata map[string]string
+}
+
+// InitiateCustomAuth starts a CUSTOM_AUTH flow. The result usually holds a CUSTOM_CHALLENGE
+// whose ChallengeParameters are the public challenge parameters of the Create Auth Challenge
+// trigger, answer it with RespondToCustomChallenge.
+func (c *AppClient) InitiateCustomAuth(opts *CustomAuthOptions) (*AuthResult, error) {
+	if opts.Username == "" {
+		return nil, errors.New("username is required")
+	}
+
+	var srp *srpClient
+	var err error
+	if opts.Password != "" {
+		srp, err = newSRPClient(srpPoolName(c.UserPoolID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	device, err := c.rememberedDevice(opts.Username)
+	if err != nil {
+		return nil, err
+	}
+	params := c.customAuthParams(opts.Username, srp, device)
+
+	input := &cognitoidentityprovider.InitiateAuthInput{
+		AuthFlow:       aws.String(cognitoidentityprovider.AuthFlowTypeCustomAuth),
+		AuthParameters: aws.StringMap(params),
+		ClientId:       aws.String(c.ClientID),
+	}
+	if len(opts.ClientMetadata) > 0 {
+		input.ClientMetadata = aws.StringMap(opts.ClientMetadata)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.InitiateAuth(input)
+	if err != nil {
+		return nil, err
+	}
+	prev := &AuthResult{Username: opts.Username, ClientMetadata: opts.ClientMetadata, loginName: opts.Username}
+	r := newAuthResult(prev, out.AuthenticationResult, out.ChallengeName, out.ChallengeParameters, out.Session)
+
+	if r.ChallengeName == cognitoidentityprovider.ChallengeNameTypePasswordVerifier {
+		if srp == nil {
+			return nil, errors.New("PASSWORD_VERIFIER challenge without a password")
+		}
+		r, err = c.passwordVerifier(cip, srp, r, opts.Password, device)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.finishAuth(cip, r)
+}
+
+// RespondToCustomChallenge answers a CUSTOM_CHALLENGE, the result is either the tokens or
+// the next round of the challenge as decided by the Define Auth Challenge trigger
+func (c *AppClient) RespondToCustomChallenge(prev *AuthResult, answer string) (*AuthResult, error) {
+	if prev == nil || prev.ChallengeName != cognitoidentityprovider.ChallengeNameTypeCustomChallenge {
+		return nil, errors.New("no CUSTOM_CHALLENGE to respond to")
+	}
+	return c.RespondToAuthChallenge(prev, map[string]string{"ANSWER": answer})
+}
+
+// customAuthParams builds the AuthParameters of a CUSTOM_AUTH flow,
+// with SRP_A to verify the password first if srp is set
+func (c *AppClient) customAuthParams(username string, srp *srpClient, device *DeviceSecrets) map[string]string {
+	params := map[string]string{"USERNAME": username}
+	if srp != nil {
+		params["CHALLENGE_NAME"] = "SRP_A"
+		params["SRP_A"] = srp.SRPA()
+	}
+	c.addSecretHash(params, username)
+	if device != nil {
+		params["DEVICE_KEY"] = device.DeviceKey
+	}
+	return params
+}
@@ -0,0 +1,30 @@
+package cognito
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomAuthParams(t *testing.T) {
+	c := &AppClient{ClientID: "client", ClientSecret: "secret"}
+
+	params := c.customAuthParams("jdoe", nil, nil)
+	assert.Equal(t, map[string]string{
+		"USERNAME":    "jdoe",
+		"SECRET_HASH": "VOSESiBwKvcMZg9OizXU8wg2TGS6RhoITHYBwyX6UjM=",
+	}, params)
+
+	srp, err := newSRPClient("abc")
+	assert.Nil(t, err)
+	params = c.customAuthParams("jdoe", srp, &DeviceSecrets{DeviceKey: "device"})
+	assert.Equal(t, "SRP_A", params["CHALLENGE_NAME"])
+	assert.Equal(t, srp.SRPA(), params["SRP_A"])
+	assert.Equal(t, "device", params["DEVICE_KEY"])
+}
+
+func TestRespondToCustomChallengeRequiresChallenge(t *testing.T) {
+	c := &AppClient{}
+	_, err := c.RespondToCustomChallenge(&AuthResult{ChallengeName: "SMS_MFA"}, "1234")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,280 @@
+package cognito
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// DefaultDeviceName is the name devices are confirmed with if AppClient.DeviceName is empty
+const DefaultDeviceName = "cognito-go"
+
+// errNoDeviceStore is returned when device secrets are needed without a DeviceStore
+var errNoDeviceStore = errors.New("app client has no DeviceStore")
+
+// DeviceMetadata identifies a device Cognito started tracking
+type DeviceMetadata struct {
+	DeviceKey      string `json:"deviceKey"`
+	DeviceGroupKey string `json:"deviceGroupKey"`
+}
+
+// DeviceSecrets are the values a client needs to authenticate as a remembered device.
+// DevicePassword is a secret and must be stored as such.
+type DeviceSecrets struct {
+	DeviceKey      string `json:"deviceKey"`
+	DeviceGroupKey string `json:"deviceGroupKey"`
+	DevicePassword string `json:"devicePassword"`
+}
+
+// DeviceStore persists the remembered device of each user
+type DeviceStore interface {
+	// GetDevice returns the device of the user, or nil if there is none
+	GetDevice(username string) (*DeviceSecrets, error)
+	// SaveDevice stores the device of the user
+	SaveDevice(username string, device *DeviceSecrets) error
+	// DeleteDevice forgets the device of the user
+	DeleteDevice(username string) error
+}
+
+// MemoryDeviceStore is a DeviceStore that keeps devices in memory
+type MemoryDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string]*DeviceSecrets
+}
+
+// NewMemoryDeviceStore returns an empty MemoryDeviceStore
+func NewMemoryDeviceStore() *MemoryDeviceStore {
+	return &MemoryDeviceStore{devices: map[string]*DeviceSecrets{}}
+}
+
+// GetDevice implements DeviceStore
+func (s *MemoryDeviceStore) GetDevice(username string) (*DeviceSecrets, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.devices[username], nil
+}
+
+// SaveDevice implements DeviceStore
+func (s *MemoryDeviceStore) SaveDevice(username string, device *DeviceSecrets) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[username] = device
+	return nil
+}
+
+// DeleteDevice implements DeviceStore
+func (s *MemoryDeviceStore) DeleteDevice(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, username)
+	return nil
+}
+
+// rememberedDevice returns the stored device of the user, or nil if there is no DeviceStore
+func (c *AppClient) rememberedDevice(username string) (*DeviceSecrets, error) {
+	if c.DeviceStore == nil || username == "" {
+		return nil, nil
+	}
+	return c.DeviceStore.GetDevice(username)
+}
+
+// deviceSRPAuth answers the DEVICE_SRP_AUTH and DEVICE_PASSWORD_VERIFIER challenges
+func (c *AppClient) deviceSRPAuth(cip *cognitoidentityprovider.CognitoIdentityProvider, r *AuthResult, device *DeviceSecrets) (*AuthResult, error) {
+	srp, err := newSRPClient(device.DeviceGroupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := map[string]string{
+		"USERNAME":   r.Username,
+		"DEVICE_KEY": device.DeviceKey,
+		"SRP_A":      srp.SRPA(),
+	}
+	c.addSecretHash(responses, r.Username)
+	r, err = c.respondToAuthChallenge(cip, r, responses)
+	if err != nil {
+		return nil, err
+	}
+	if r.ChallengeName != cognitoidentityprovider.ChallengeNameTypeDevicePasswordVerifier {
+		return r, nil
+	}
+
+	p := r.ChallengeParameters
+	timestamp, signature, err := srp.passwordClaim(device.DeviceKey, device.DevicePassword, p["SRP_B"], p["SALT"], p["SECRET_BLOCK"], time.Now())
+	if err != nil {
+		return nil, err
+	}
+	responses = map[string]string{
+		"USERNAME":                    r.Username,
+		"DEVICE_KEY":                  device.DeviceKey,
+		"PASSWORD_CLAIM_SECRET_BLOCK": p["SECRET_BLOCK"],
+		"PASSWORD_CLAIM_SIGNATURE":    signature,
+		"TIMESTAMP":                   timestamp,
+	}
+	c.addSecretHash(responses, r.Username)
+
+	return c.respondToAuthChallenge(cip, r, responses)
+}
+
+// confirmDevice registers the new device of an authenticated user with a freshly generated
+// SRP verifier, remembers it and saves its secrets in the DeviceStore
+func (c *AppClient) confirmDevice(cip *cognitoidentityprovider.CognitoIdentityProvider, r *AuthResult) error {
+	password, err := randomPassword()
+	if err != nil {
+		return err
+	}
+	salt, verifier, err := deviceVerifier(r.NewDevice.DeviceGroupKey, r.NewDevice.DeviceKey, password)
+	if err != nil {
+		return err
+	}
+
+	name := c.DeviceName
+	if name == "" {
+		name = DefaultDeviceName
+	}
+	out, err := cip.ConfirmDevice(&cognitoidentityprovider.ConfirmDeviceInput{
+		AccessToken: aws.String(r.Token.AccessToken),
+		DeviceKey:   aws.String(r.NewDevice.DeviceKey),
+		DeviceName:  aws.String(name),
+		DeviceSecretVerifierConfig: &cognitoidentityprovider.DeviceSecretVerifierConfigType{
+			PasswordVerifier: aws.String(verifier),
+			Salt:             aws.String(salt),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// With "user opt-in" device remembering the device is only tracked until it is remembered,
+	// configuring a DeviceStore is the opt-in
+	if aws.BoolValue(out.UserConfirmationNecessary) {
+		_, err = cip.UpdateDeviceStatus(&cognitoidentityprovider.UpdateDeviceStatusInput{
+			AccessToken:            aws.String(r.Token.AccessToken),
+			DeviceKey:              aws.String(r.NewDevice.DeviceKey),
+			DeviceRememberedStatus: aws.String(cognitoidentityprovider.DeviceRememberedStatusTypeRemembered),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	secrets := &DeviceSecrets{
+		DeviceKey:      r.NewDevice.DeviceKey,
+		DeviceGroupKey: r.NewDevice.DeviceGroupKey,
+		DevicePassword: password,
+	}
+	for _, username := range []string{r.loginName, r.Username} {
+		if username == "" {
+			continue
+		}
+		if err = c.DeviceStore.SaveDevice(username, secrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDevices lists all devices tracked for the user the access token belongs to
+func (c *AppClient) ListDevices(accessToken string) ([]*cognitoidentityprovider.DeviceType, error) {
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []*cognitoidentityprovider.DeviceType
+	input := &cognitoidentityprovider.ListDevicesInput{
+		AccessToken: aws.String(accessToken),
+	}
+	for {
+		out, err := cip.ListDevices(input)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, out.Devices...)
+		if aws.StringValue(out.PaginationToken) == "" {
+			break
+		}
+		input.PaginationToken = out.PaginationToken
+	}
+
+	return devices, nil
+}
+
+// GetDevice gets a device of the user the access token belongs to
+func (c *AppClient) GetDevice(accessToken, deviceKey string) (*cognitoidentityprovider.DeviceType, error) {
+	input := &cognitoidentityprovider.GetDeviceInput{
+		AccessToken: aws.String(accessToken),
+		DeviceKey:   aws.String(deviceKey),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.GetDevice(input)
+	if err != nil {
+		return nil, err
+	}
+	return out.Device, nil
+}
+
+// ForgetDevice stops tracking a device of the user the access token belongs to
+func (c *AppClient) ForgetDevice(accessToken, deviceKey string) error {
+	input := &cognitoidentityprovider.ForgetDeviceInput{
+		AccessToken: aws.String(accessToken),
+		DeviceKey:   aws.String(deviceKey),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.ForgetDevice(input)
+	return err
+}
+
+// UpdateDeviceStatus sets whether a device of the user the access token belongs to is remembered
+func (c *AppClient) UpdateDeviceStatus(accessToken, deviceKey string, remembered bool) error {
+	status := cognitoidentityprovider.DeviceRememberedStatusTypeNotRemembered
+	if remembered {
+		status = cognitoidentityprovider.DeviceRememberedStatusTypeRemembered
+	}
+	input := &cognitoidentityprovider.UpdateDeviceStatusInput{
+		AccessToken:            aws.String(accessToken),
+		DeviceKey:              aws.String(deviceKey),
+		DeviceRememberedStatus: aws.String(status),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.UpdateDeviceStatus(input)
+	return err
+}
+
+// ForgetRememberedDevice forgets the device stored for username, both in Cognito
+// and in the DeviceStore
+func (c *AppClient) ForgetRememberedDevice(accessToken, username string) error {
+	if c.DeviceStore == nil {
+		return errNoDeviceStore
+	}
+	device, err := c.DeviceStore.GetDevice(username)
+	if err != nil || device == nil {
+		return err
+	}
+	if err = c.ForgetDevice(accessToken, device.DeviceKey); err != nil {
+		return err
+	}
+	return c.DeviceStore.DeleteDevice(username)
+}
@@ -0,0 +1,282 @@
+package cognito
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// ExportFormat is the output format of ExportUsers
+type ExportFormat string
+
+// Formats of ExportUsers
+const (
+	// ExportJSONL writes one JSON object per user and line
+	ExportJSONL ExportFormat = "jsonl"
+	// ExportCSV writes one row per user with a column per attribute
+	ExportCSV ExportFormat = "csv"
+)
+
+// DefaultExportRequestsPerSecond paces the calls of ExportUsers well below the
+// Cognito quota for reading users, leaving room for other clients of the pool
+const DefaultExportRequestsPerSecond = 5
+
+// maxListUsersLimit is the largest page ListUsers returns
+const maxListUsersLimit = 60
+
+// exportColumns are the CSV columns before the attributes
+var exportColumns = []string{"username", "status", "enabled", "created_at", "modified_at"}
+
+// ExportedUser is a user as ExportUsers writes it
+type ExportedUser struct {
+	*User
+	// Groups are set if ExportOptions.IncludeGroups is set
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ExportOptions controls ExportUsers
+type ExportOptions struct {
+	// Filter is a Cognito filter expression, see UserFilter
+	Filter string
+	// Attributes are the attributes to export. For CSV they are the columns, all attributes
+	// of the pool's schema if empty.
+	Attributes []string
+	// IncludeGroups looks up the groups of every user, which costs a call per user
+	IncludeGroups bool
+	// RequestsPerSecond paces the calls, defaults to DefaultExportRequestsPerSecond
+	RequestsPerSecond float64
+	// MaxRetries is the number of retries of a throttled call, DefaultMaxRetries if 0, none if negative
+	MaxRetries int
+	// PaginationToken resumes an export after the page the token was checkpointed for.
+	// No CSV header is written when resuming, so the output can be appended to the old one.
+	PaginationToken string
+	// Checkpoint is called with the token of the next page after each page was written,
+	// and with an empty token when the export is complete
+	Checkpoint func(paginationToken string) error
+}
+
+// ExportResult summarizes an export
+type ExportResult struct {
+	Users int `json:"users"`
+	Pages int `json:"pages"`
+	// Retries counts the retries of throttled calls
+	Retries int `json:"retries"`
+	// PaginationToken is the token to resume with if the export failed
+	PaginationToken string `json:"paginationToken,omitempty"`
+}
+
+// ExportUsers writes all users of the pool, or those matching the filter, to w. Pages are
+// fetched one at a time at a paced rate and throttled calls are retried, each page is written
+// as a whole before the next one is fetched. If an error is returned, the export can be resumed with the
+// PaginationToken of the result.
+// Requires a AWS session with developer credentials
+func (c *AppClient) ExportUsers(w io.Writer, format ExportFormat, opts *ExportOptions) (*ExportResult, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	if format != ExportJSONL && format != ExportCSV {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := opts.Attributes
+	if format == ExportCSV && len(attributes) == 0 {
+		if attributes, err = c.schemaAttributes(cip); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := aws.BackgroundContext()
+	list := func(token string) (users []*cognitoidentityprovider.UserType, next string, retries int, err error) {
+		input := &cognitoidentityprovider.ListUsersInput{
+			UserPoolId: &c.UserPoolID,
+			Limit:      aws.Int64(maxListUsersLimit),
+		}
+		if opts.Filter != "" {
+			input.Filter = aws.String(opts.Filter)
+		}
+		if len(opts.Attributes) > 0 {
+			input.AttributesToGet = attributeNames(opts.Attributes)
+		}
+		if token != "" {
+			input.PaginationToken = aws.String(token)
+		}
+		out, err := cip.ListUsersWithContext(ctx, input, c.withRetries(opts.MaxRetries, 0, &retries))
+		if err != nil {
+			return nil, "", retries, err
+		}
+		return out.Users, aws.StringValue(out.PaginationToken), retries, nil
+	}
+
+	var groups func(username string) ([]string, int, error)
+	if opts.IncludeGroups {
+		groups = func(username string) (names []string, retries int, err error) {
+			err = cip.AdminListGroupsForUserPagesWithContext(ctx, &cognitoidentityprovider.AdminListGroupsForUserInput{
+				Username:   aws.String(username),
+				UserPoolId: &c.UserPoolID,
+			}, func(out *cognitoidentityprovider.AdminListGroupsForUserOutput, last bool) bool {
+				for _, g := range out.Groups {
+					names = append(names, aws.StringValue(g.GroupName))
+				}
+				return true
+			}, c.withRetries(opts.MaxRetries, 0, &retries))
+			return names, retries, err
+		}
+	}
+
+	return exportUsers(newExportWriter(w, format, attributes, opts), opts, list, groups)
+}
+
+// schemaAttributes returns the attribute names of the pool's schema, sorted
+func (c *AppClient) schemaAttributes(cip *cognitoidentityprovider.CognitoIdentityProvider) ([]string, error) {
+	out, err := cip.DescribeUserPool(&cognitoidentityprovider.DescribeUserPoolInput{
+		UserPoolId: &c.UserPoolID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, a := range out.UserPool.SchemaAttributes {
+		names = append(names, aws.StringValue(a.Name))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// exportUsers pages through list and writes every user, the functions are the Cognito calls
+// and return the retries they took
+func exportUsers(ew exportWriter, opts *ExportOptions, list func(token string) ([]*cognitoidentityprovider.UserType, string, int, error), groups func(username string) ([]string, int, error)) (*ExportResult, error) {
+	rate := opts.RequestsPerSecond
+	if rate <= 0 {
+		rate = DefaultExportRequestsPerSecond
+	}
+	// A burst of one spaces the calls evenly
+	pace := newTokenBucket(rate, 1)
+	res := &ExportResult{PaginationToken: opts.PaginationToken}
+
+	if opts.PaginationToken == "" {
+		if err := ew.header(); err != nil {
+			return res, err
+		}
+	}
+	for {
+		pace.wait()
+		users, next, retries, err := list(res.PaginationToken)
+		res.Retries += retries
+		if err != nil {
+			return res, err
+		}
+
+		// The page is only written once its groups are known, so a failed page leaves
+		// nothing behind that resuming with its token would write again
+		page := make([]ExportedUser, len(users))
+		for i, ut := range users {
+			page[i].User = NewUser(ut)
+			if groups != nil {
+				pace.wait()
+				page[i].Groups, retries, err = groups(page[i].Username)
+				res.Retries += retries
+				if err != nil {
+					return res, fmt.Errorf("listing groups of %s: %v", page[i].Username, err)
+				}
+			}
+		}
+		for i := range page {
+			if err = ew.write(&page[i]); err != nil {
+				return res, err
+			}
+		}
+		// The page must be written before its checkpoint
+		if err = ew.flush(); err != nil {
+			return res, err
+		}
+		res.Users += len(users)
+		res.Pages++
+		res.PaginationToken = next
+		if opts.Checkpoint != nil {
+			if err = opts.Checkpoint(next); err != nil {
+				return res, err
+			}
+		}
+		if next == "" {
+			return res, nil
+		}
+	}
+}
+
+// exportWriter writes users in an export format
+type exportWriter interface {
+	header() error
+	write(u *ExportedUser) error
+	flush() error
+}
+
+func newExportWriter(w io.Writer, format ExportFormat, attributes []string, opts *ExportOptions) exportWriter {
+	if format == ExportCSV {
+		columns := make([]string, len(attributes))
+		for i, a := range attributes {
+			columns[i] = AttributeName(a)
+		}
+		return &csvExportWriter{w: csv.NewWriter(w), attributes: columns, groups: opts.IncludeGroups}
+	}
+	return &jsonlExportWriter{enc: json.NewEncoder(w)}
+}
+
+type jsonlExportWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonlExportWriter) header() error { return nil }
+
+func (jw *jsonlExportWriter) write(u *ExportedUser) error { return jw.enc.Encode(u) }
+
+func (jw *jsonlExportWriter) flush() error { return nil }
+
+// csvExportWriter writes the attributes in fixed columns, groups are joined with ";"
+type csvExportWriter struct {
+	w          *csv.Writer
+	attributes []string
+	groups     bool
+}
+
+func (cw *csvExportWriter) header() error {
+	header := append(append([]string{}, exportColumns...), cw.attributes...)
+	if cw.groups {
+		header = append(header, "groups")
+	}
+	return cw.w.Write(header)
+}
+
+func (cw *csvExportWriter) write(u *ExportedUser) error {
+	row := []string{
+		u.Username,
+		string(u.Status),
+		strconv.FormatBool(u.Enabled),
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		u.ModifiedAt.UTC().Format(time.RFC3339),
+	}
+	for _, a := range cw.attributes {
+		row = append(row, u.Attributes[a])
+	}
+	if cw.groups {
+		row = append(row, strings.Join(u.Groups, ";"))
+	}
+	return cw.w.Write(row)
+}
+
+func (cw *csvExportWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
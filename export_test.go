@@ -0,0 +1,160 @@
+package cognito
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportPages fakes ListUsers with two pages, "" and "page-2" are the tokens.
+// Page 2 takes a retry.
+func exportPages(failPage2 bool) func(token string) ([]*cognitoidentityprovider.UserType, string, int, error) {
+	created := time.Date(2020, 2, 1, 12, 0, 0, 0, time.UTC)
+	user := func(name, email string) *cognitoidentityprovider.UserType {
+		return &cognitoidentityprovider.UserType{
+			Username:             aws.String(name),
+			UserStatus:           aws.String("CONFIRMED"),
+			Enabled:              aws.Bool(true),
+			UserCreateDate:       &created,
+			UserLastModifiedDate: &created,
+			Attributes: attributeTypes(map[string]string{
+				"email":  email,
+				"tenant": "acme",
+			}),
+		}
+	}
+	return func(token string) ([]*cognitoidentityprovider.UserType, string, int, error) {
+		switch token {
+		case "":
+			return []*cognitoidentityprovider.UserType{user("alice", "alice@example.com"), user("bob", "bob@example.com")}, "page-2", 0, nil
+		case "page-2":
+			if failPage2 {
+				return nil, "", 1, errors.New("connection reset")
+			}
+			return []*cognitoidentityprovider.UserType{user("carol", "carol@example.com")}, "", 1, nil
+		}
+		return nil, "", 0, errors.New("invalid token " + token)
+	}
+}
+
+func TestExportUsersJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	var checkpoints []string
+	opts := &ExportOptions{
+		RequestsPerSecond: 1000,
+		Checkpoint: func(token string) error {
+			checkpoints = append(checkpoints, token)
+			return nil
+		},
+	}
+	res, err := exportUsers(newExportWriter(&buf, ExportJSONL, nil, opts), opts, exportPages(false), func(username string) ([]string, int, error) {
+		return []string{"admins"}, 0, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, &ExportResult{Users: 3, Pages: 2, Retries: 1}, res)
+	assert.Equal(t, []string{"page-2", ""}, checkpoints)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"username":"alice"`)
+	assert.Contains(t, lines[0], `"custom:tenant":"acme"`)
+	assert.Contains(t, lines[0], `"groups":["admins"]`)
+}
+
+func TestExportUsersCSVResume(t *testing.T) {
+	opts := &ExportOptions{RequestsPerSecond: 1000, MaxRetries: 1, IncludeGroups: true}
+	columns := []string{"email", "tenant"}
+	groups := func(username string) ([]string, int, error) { return []string{"admins", "users"}, 0, nil }
+
+	// The second page fails, the first one is written and can be resumed after
+	var buf bytes.Buffer
+	res, err := exportUsers(newExportWriter(&buf, ExportCSV, columns, opts), opts, exportPages(true), groups)
+	assert.NotNil(t, err)
+	assert.Equal(t, "page-2", res.PaginationToken)
+	assert.Equal(t, strings.Join([]string{
+		"username,status,enabled,created_at,modified_at,email,custom:tenant,groups",
+		"alice,CONFIRMED,true,2020-02-01T12:00:00Z,2020-02-01T12:00:00Z,alice@example.com,acme,admins;users",
+		"bob,CONFIRMED,true,2020-02-01T12:00:00Z,2020-02-01T12:00:00Z,bob@example.com,acme,admins;users",
+		"",
+	}, "\n"), buf.String())
+
+	opts.PaginationToken = res.PaginationToken
+	buf.Reset()
+	res, err = exportUsers(newExportWriter(&buf, ExportCSV, columns, opts), opts, exportPages(false), groups)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Users)
+	assert.Equal(t, "carol,CONFIRMED,true,2020-02-01T12:00:00Z,2020-02-01T12:00:00Z,carol@example.com,acme,admins;users\n", buf.String())
+}
+
+func TestExportUsersResumeAfterGroupsFail(t *testing.T) {
+	opts := &ExportOptions{RequestsPerSecond: 1000, IncludeGroups: true}
+	failed := false
+	groups := func(username string) ([]string, int, error) {
+		// bob's groups fail once, after alice's were listed
+		if username == "bob" && !failed {
+			failed = true
+			return nil, 0, errors.New("connection reset")
+		}
+		return []string{"users"}, 0, nil
+	}
+
+	// Nothing of the failed page is written, so resuming does not repeat alice
+	var buf bytes.Buffer
+	res, err := exportUsers(newExportWriter(&buf, ExportJSONL, nil, opts), opts, exportPages(false), groups)
+	assert.EqualError(t, err, "listing groups of bob: connection reset")
+	assert.Equal(t, 0, res.Users)
+	assert.Equal(t, "", res.PaginationToken)
+	assert.Empty(t, buf.String())
+
+	opts.PaginationToken = res.PaginationToken
+	res, err = exportUsers(newExportWriter(&buf, ExportJSONL, nil, opts), opts, exportPages(false), groups)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Users)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Contains(t, lines[0], `"username":"alice"`)
+		assert.Contains(t, lines[1], `"username":"bob"`)
+		assert.Contains(t, lines[2], `"username":"carol"`)
+	}
+}
+
+func TestExportPacing(t *testing.T) {
+	pace := newTokenBucket(100, 1)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		pace.wait()
+	}
+	assert.True(t, time.Since(start) >= 30*time.Millisecond, "Calls not paced")
+}
+
+func TestExportUsersRetries(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	lists := 0
+	srv.handle("ListUsers", func(in map[string]interface{}) (interface{}, string) {
+		// throttled once, then succeeds
+		if lists++; lists == 1 {
+			return nil, "TooManyRequestsException"
+		}
+		return map[string]interface{}{"Users": []map[string]interface{}{{"Username": "alice"}}}, ""
+	})
+
+	var buf bytes.Buffer
+	c := srv.client()
+	res, err := c.ExportUsers(&buf, ExportJSONL, &ExportOptions{RequestsPerSecond: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, &ExportResult{Users: 1, Pages: 1, Retries: 1}, res)
+	assert.Equal(t, 2, lists)
+
+	lists = 0
+	res, err = c.ExportUsers(&buf, ExportJSONL, &ExportOptions{RequestsPerSecond: 1000, MaxRetries: -1})
+	assert.True(t, IsThrottlingError(err))
+	assert.Equal(t, 0, res.Retries)
+	assert.Equal(t, 1, lists)
+}
@@ -9,4 +9,5 @@ require (
 	github.com/gobuffalo/envy v1.7.0
 	github.com/lestrrat-go/jwx v0.9.0
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,174 @@
+package cognito
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// GroupOptions defines a group for CreateGroup and UpdateGroup
+type GroupOptions struct {
+	// Name of the group, required
+	Name string
+	// Description of the group
+	Description string
+	// Precedence decides which group's role applies when a user is in several groups,
+	// lower values take priority. Nil leaves it unset.
+	Precedence *int64
+	// RoleArn is the IAM role of the group members
+	RoleArn string
+}
+
+// CreateGroup creates a new group in the user pool
+// Requires a AWS session with developer credentials
+func (c *AppClient) CreateGroup(opts *GroupOptions) (*cognitoidentityprovider.GroupType, error) {
+	if opts.Name == "" {
+		return nil, errors.New("group name is required")
+	}
+	input := &cognitoidentityprovider.CreateGroupInput{
+		GroupName:  aws.String(opts.Name),
+		Precedence: opts.Precedence,
+		UserPoolId: &c.UserPoolID,
+	}
+	if opts.Description != "" {
+		input.Description = aws.String(opts.Description)
+	}
+	if opts.RoleArn != "" {
+		input.RoleArn = aws.String(opts.RoleArn)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.CreateGroup(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Group, nil
+}
+
+// UpdateGroup updates the description, precedence and role of a group,
+// empty fields are left unchanged
+// Requires a AWS session with developer credentials
+func (c *AppClient) UpdateGroup(opts *GroupOptions) (*cognitoidentityprovider.GroupType, error) {
+	if opts.Name == "" {
+		return nil, errors.New("group name is required")
+	}
+	input := &cognitoidentityprovider.UpdateGroupInput{
+		GroupName:  aws.String(opts.Name),
+		Precedence: opts.Precedence,
+		UserPoolId: &c.UserPoolID,
+	}
+	if opts.Description != "" {
+		input.Description = aws.String(opts.Description)
+	}
+	if opts.RoleArn != "" {
+		input.RoleArn = aws.String(opts.RoleArn)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.UpdateGroup(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Group, nil
+}
+
+// DeleteGroup deletes a group, its members are not deleted
+// Requires a AWS session with developer credentials
+func (c *AppClient) DeleteGroup(group string) error {
+	input := &cognitoidentityprovider.DeleteGroupInput{
+		GroupName:  aws.String(group),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.DeleteGroup(input)
+	return err
+}
+
+// ListGroups lists all groups in the user pool, following all pages
+// Requires a AWS session with developer credentials
+func (c *AppClient) ListGroups() ([]*cognitoidentityprovider.GroupType, error) {
+	input := &cognitoidentityprovider.ListGroupsInput{
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*cognitoidentityprovider.GroupType
+	err = cip.ListGroupsPages(input, func(out *cognitoidentityprovider.ListGroupsOutput, last bool) bool {
+		groups = append(groups, out.Groups...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// RemoveUserFromGroup removes a user from a group
+// Requires a AWS session with developer credentials
+func (c *AppClient) RemoveUserFromGroup(username, group string) error {
+	input := &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+		Username:   aws.String(username),
+		GroupName:  aws.String(group),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.AdminRemoveUserFromGroup(input)
+	return err
+}
+
+// ListUsersInGroup lists all members of a group, following all pages
+// Requires a AWS session with developer credentials
+func (c *AppClient) ListUsersInGroup(group string) ([]*cognitoidentityprovider.UserType, error) {
+	input := &cognitoidentityprovider.ListUsersInGroupInput{
+		GroupName:  aws.String(group),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*cognitoidentityprovider.UserType
+	err = cip.ListUsersInGroupPages(input, func(out *cognitoidentityprovider.ListUsersInGroupOutput, last bool) bool {
+		users = append(users, out.Users...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,240 @@
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// cipCall is a request to the fake Cognito endpoint
+type cipCall struct {
+	Operation string
+	Input     map[string]interface{}
+}
+
+// cipServer fakes the Cognito user pool API. Handlers are keyed by operation and return
+// the output, or an error code to respond with.
+type cipServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	calls    []cipCall
+	handlers map[string]func(in map[string]interface{}) (out interface{}, errCode string)
+	// transport is the http.DefaultClient transport to restore on Close
+	transport http.RoundTripper
+}
+
+func newCIPServer(t *testing.T) *cipServer {
+	s := &cipServer{handlers: map[string]func(map[string]interface{}) (interface{}, string){}}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AWSCognitoIdentityProviderService.")
+		var in map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&in))
+		s.mu.Lock()
+		s.calls = append(s.calls, cipCall{op, in})
+		handler := s.handlers[op]
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if handler == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "InvalidParameterException", "message": "unexpected " + op})
+			return
+		}
+		out, errCode := handler(in)
+		if errCode != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": errCode, "message": errCode})
+			return
+		}
+		if out == nil {
+			out = map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+
+	// AWS sessions use http.DefaultClient, dial the fake endpoint for every host. Its
+	// certificate is not for the AWS hosts, so it is not verified.
+	s.transport = http.DefaultClient.Transport
+	fake := s.Server.Client().Transport.(*http.Transport).Clone()
+	fake.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, s.Listener.Addr().String())
+	}
+	fake.TLSClientConfig.InsecureSkipVerify = true
+	http.DefaultClient.Transport = fake
+	return s
+}
+
+// Close stops the server and restores http.DefaultClient
+func (s *cipServer) Close() {
+	http.DefaultClient.Transport = s.transport
+	s.Server.Close()
+}
+
+// client returns an AppClient calling the fake endpoint, with static credentials
+func (s *cipServer) client() *AppClient {
+	return &AppClient{
+		AWSAccessKey:       "id",
+		AWSSecretAccessKey: "secret",
+		Region:             "us-east-1",
+		UserPoolID:         "us-east-1_Pool",
+	}
+}
+
+func (s *cipServer) handle(op string, handler func(in map[string]interface{}) (interface{}, string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[op] = handler
+}
+
+// pages returns a handler that serves the pages of a paginated operation, the token
+// of page i is "page-i"
+func pages(tokenIn, tokenOut, key string, items ...[]map[string]interface{}) func(map[string]interface{}) (interface{}, string) {
+	return func(in map[string]interface{}) (interface{}, string) {
+		i := 0
+		if token, ok := in[tokenIn].(string); ok {
+			i, _ = strconv.Atoi(strings.TrimPrefix(token, "page-"))
+		}
+		out := map[string]interface{}{key: items[i]}
+		if i+1 < len(items) {
+			out[tokenOut] = "page-" + strconv.Itoa(i+1)
+		}
+		return out, ""
+	}
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	c := srv.client()
+
+	group := func(in map[string]interface{}) (interface{}, string) {
+		return map[string]interface{}{"Group": map[string]interface{}{
+			"GroupName":   in["GroupName"],
+			"Description": in["Description"],
+			"Precedence":  in["Precedence"],
+			"UserPoolId":  in["UserPoolId"],
+		}}, ""
+	}
+	srv.handle("CreateGroup", group)
+	srv.handle("UpdateGroup", group)
+	srv.handle("DeleteGroup", func(in map[string]interface{}) (interface{}, string) { return nil, "" })
+
+	g, err := c.CreateGroup(&GroupOptions{Name: "admins", Description: "Administrators", Precedence: aws.Int64(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, "admins", aws.StringValue(g.GroupName))
+	assert.Equal(t, int64(1), aws.Int64Value(g.Precedence))
+
+	g, err = c.UpdateGroup(&GroupOptions{Name: "admins", RoleArn: "arn:aws:iam::123456789012:role/admin"})
+	assert.Nil(t, err)
+	assert.Equal(t, "admins", aws.StringValue(g.GroupName))
+
+	assert.Nil(t, c.DeleteGroup("admins"))
+
+	_, err = c.CreateGroup(&GroupOptions{})
+	assert.EqualError(t, err, "group name is required")
+	_, err = c.UpdateGroup(&GroupOptions{})
+	assert.EqualError(t, err, "group name is required")
+
+	assert.Equal(t, []cipCall{
+		{"CreateGroup", map[string]interface{}{"GroupName": "admins", "Description": "Administrators", "Precedence": 1.0, "UserPoolId": "us-east-1_Pool"}},
+		{"UpdateGroup", map[string]interface{}{"GroupName": "admins", "RoleArn": "arn:aws:iam::123456789012:role/admin", "UserPoolId": "us-east-1_Pool"}},
+		{"DeleteGroup", map[string]interface{}{"GroupName": "admins", "UserPoolId": "us-east-1_Pool"}},
+	}, srv.calls)
+}
+
+func TestListGroupsPages(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("ListGroups", pages("NextToken", "NextToken", "Groups",
+		[]map[string]interface{}{{"GroupName": "admins"}, {"GroupName": "editors"}},
+		[]map[string]interface{}{{"GroupName": "viewers"}},
+	))
+
+	groups, err := srv.client().ListGroups()
+	assert.Nil(t, err)
+	var names []string
+	for _, g := range groups {
+		names = append(names, aws.StringValue(g.GroupName))
+	}
+	assert.Equal(t, []string{"admins", "editors", "viewers"}, names)
+	assert.Len(t, srv.calls, 2)
+	assert.Equal(t, "page-1", srv.calls[1].Input["NextToken"])
+}
+
+func TestListUsersInGroupPages(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("ListUsersInGroup", pages("NextToken", "NextToken", "Users",
+		[]map[string]interface{}{{"Username": "alice"}},
+		[]map[string]interface{}{{"Username": "bob"}},
+		[]map[string]interface{}{{"Username": "carol"}},
+	))
+
+	users, err := srv.client().ListUsersInGroup("admins")
+	assert.Nil(t, err)
+	var names []string
+	for _, u := range users {
+		names = append(names, aws.StringValue(u.Username))
+	}
+	assert.Equal(t, []string{"alice", "bob", "carol"}, names)
+	assert.Len(t, srv.calls, 3)
+	for _, call := range srv.calls {
+		assert.Equal(t, "admins", call.Input["GroupName"])
+	}
+
+	srv.handle("ListUsersInGroup", func(in map[string]interface{}) (interface{}, string) {
+		return nil, "ResourceNotFoundException"
+	})
+	_, err = srv.client().ListUsersInGroup("missing")
+	assert.NotNil(t, err)
+}
+
+func TestRemoveUserFromGroup(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("AdminRemoveUserFromGroup", func(in map[string]interface{}) (interface{}, string) {
+		if in["Username"] == "ghost" {
+			return nil, "UserNotFoundException"
+		}
+		return nil, ""
+	})
+
+	c := srv.client()
+	assert.Nil(t, c.RemoveUserFromGroup("alice", "admins"))
+	assert.Equal(t, cipCall{"AdminRemoveUserFromGroup", map[string]interface{}{
+		"Username": "alice", "GroupName": "admins", "UserPoolId": "us-east-1_Pool",
+	}}, srv.calls[0])
+
+	err := c.RemoveUserFromGroup("ghost", "admins")
+	assert.EqualError(t, err, "UserNotFoundException: UserNotFoundException")
+}
+
+func TestGetUserGroupsPages(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("AdminListGroupsForUser", pages("NextToken", "NextToken", "Groups",
+		[]map[string]interface{}{{"GroupName": "admins"}},
+		[]map[string]interface{}{{"GroupName": "editors"}},
+	))
+
+	groups, err := srv.client().GetUserGroups("jdoe")
+	assert.Nil(t, err)
+	var names []string
+	for _, g := range groups {
+		names = append(names, aws.StringValue(g.GroupName))
+	}
+	assert.Equal(t, []string{"admins", "editors"}, names)
+	assert.Len(t, srv.calls, 2)
+	for _, call := range srv.calls {
+		assert.Equal(t, "jdoe", call.Input["Username"])
+	}
+}
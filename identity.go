@@ -0,0 +1,244 @@
+package cognito
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cognitoidentity"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// IdentityCredentialsProviderName is the ProviderName of credentials from an IdentityPool
+const IdentityCredentialsProviderName = "CognitoIdentityPoolProvider"
+
+// DefaultIDTokenRefreshWindow is how long before its expiry an ID token is refreshed
+const DefaultIDTokenRefreshWindow = 5 * time.Minute
+
+// IdentityPool exchanges user pool ID tokens for temporary AWS credentials through a
+// Cognito identity pool. GetId and GetCredentialsForIdentity are unsigned calls, no
+// AWS credentials are needed.
+type IdentityPool struct {
+	Region         string
+	IdentityPoolID string
+	// AccountID is the AWS account that owns the identity pool, optional
+	AccountID string
+	// UserPoolRegion and UserPoolID make the login key
+	// cognito-idp.<region>.amazonaws.com/<pool_id> of the ID tokens
+	UserPoolRegion string
+	UserPoolID     string
+	// CustomRoleArn selects one of the roles the identity pool's role mapping allows
+	// for the user, instead of the default role
+	CustomRoleArn string
+	// Endpoint overrides the cognito-identity endpoint, e.g. for a VPC endpoint
+	Endpoint string
+}
+
+// IdentityCredentials are temporary AWS credentials of an identity
+type IdentityCredentials struct {
+	IdentityID      string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// NewIdentityPool returns an IdentityPool in the region of the app client, taking ID tokens
+// of the client's user pool
+func (c *AppClient) NewIdentityPool(identityPoolID string) *IdentityPool {
+	return &IdentityPool{
+		Region:         c.Region,
+		IdentityPoolID: identityPoolID,
+		UserPoolRegion: c.Region,
+		UserPoolID:     c.UserPoolID,
+	}
+}
+
+// LoginKey returns the key of the user pool in the Logins map:
+// cognito-idp.<region>.amazonaws.com/<pool_id>
+func (p *IdentityPool) LoginKey() string {
+	region := p.UserPoolRegion
+	if region == "" {
+		region = p.Region
+	}
+	return fmt.Sprintf("cognito-idp.%s.amazonaws.com/%s", region, p.UserPoolID)
+}
+
+// NewCI returns a cognito-identity client for the pool's region
+func (p *IdentityPool) NewCI() (*cognitoidentity.CognitoIdentity, error) {
+	cfg := &aws.Config{Region: aws.String(p.Region)}
+	if p.Endpoint != "" {
+		cfg.Endpoint = aws.String(p.Endpoint)
+	}
+	ses, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cognitoidentity.New(ses), nil
+}
+
+func (p *IdentityPool) logins(idToken string) map[string]*string {
+	return map[string]*string{p.LoginKey(): aws.String(idToken)}
+}
+
+// GetID returns the identity id of the user the ID token belongs to, creating the identity
+// on first use
+func (p *IdentityPool) GetID(idToken string) (string, error) {
+	ci, err := p.NewCI()
+	if err != nil {
+		return "", err
+	}
+	in := &cognitoidentity.GetIdInput{
+		IdentityPoolId: aws.String(p.IdentityPoolID),
+		Logins:         p.logins(idToken),
+	}
+	if p.AccountID != "" {
+		in.AccountId = aws.String(p.AccountID)
+	}
+	out, err := ci.GetId(in)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.IdentityId), nil
+}
+
+// GetCredentialsForIdentity returns temporary credentials of the identity, using the
+// pool's CustomRoleArn if set
+func (p *IdentityPool) GetCredentialsForIdentity(identityID, idToken string) (*IdentityCredentials, error) {
+	ci, err := p.NewCI()
+	if err != nil {
+		return nil, err
+	}
+	in := &cognitoidentity.GetCredentialsForIdentityInput{
+		IdentityId: aws.String(identityID),
+		Logins:     p.logins(idToken),
+	}
+	if p.CustomRoleArn != "" {
+		in.CustomRoleArn = aws.String(p.CustomRoleArn)
+	}
+	out, err := ci.GetCredentialsForIdentity(in)
+	if err != nil {
+		return nil, err
+	}
+	if out.Credentials == nil {
+		return nil, errors.New("no credentials returned for identity " + identityID)
+	}
+	return &IdentityCredentials{
+		IdentityID:      aws.StringValue(out.IdentityId),
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+		Expiration:      aws.TimeValue(out.Credentials.Expiration),
+	}, nil
+}
+
+// GetCredentials looks up the identity of the ID token and returns its temporary credentials
+func (p *IdentityPool) GetCredentials(idToken string) (*IdentityCredentials, error) {
+	id, err := p.GetID(idToken)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetCredentialsForIdentity(id, idToken)
+}
+
+// IdentityProvider is an AWS credentials.Provider that gets credentials of an identity pool
+// for the ID token IDToken returns, and gets new ones when they expire
+type IdentityProvider struct {
+	credentials.Expiry
+
+	Pool *IdentityPool
+	// IDToken returns a current ID token of the user, see IDTokenSource
+	IDToken func() (string, error)
+	// ExpiryWindow makes the credentials expire this much earlier, so they are not used
+	// while they time out
+	ExpiryWindow time.Duration
+
+	mu         sync.Mutex
+	identityID string
+}
+
+// NewIdentityCredentials returns AWS credentials for the identity pool that are refreshed
+// automatically with the ID token of idToken
+func NewIdentityCredentials(pool *IdentityPool, idToken func() (string, error)) *credentials.Credentials {
+	return credentials.NewCredentials(&IdentityProvider{
+		Pool:         pool,
+		IDToken:      idToken,
+		ExpiryWindow: time.Minute,
+	})
+}
+
+// Retrieve implements credentials.Provider
+func (p *IdentityProvider) Retrieve() (credentials.Value, error) {
+	v := credentials.Value{ProviderName: IdentityCredentialsProviderName}
+	idToken, err := p.IDToken()
+	if err != nil {
+		return v, err
+	}
+
+	// The identity id of a user does not change, it is looked up once
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.identityID == "" {
+		if p.identityID, err = p.Pool.GetID(idToken); err != nil {
+			return v, err
+		}
+	}
+	creds, err := p.Pool.GetCredentialsForIdentity(p.identityID, idToken)
+	if err != nil {
+		return v, err
+	}
+
+	p.SetExpiration(creds.Expiration, p.ExpiryWindow)
+	v.AccessKeyID = creds.AccessKeyID
+	v.SecretAccessKey = creds.SecretAccessKey
+	v.SessionToken = creds.SessionToken
+	return v, nil
+}
+
+// IdentityID returns the identity id once credentials have been retrieved
+func (p *IdentityProvider) IdentityID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.identityID
+}
+
+// IDTokenSource returns a function for IdentityProvider.IDToken that returns the ID token
+// of token, refreshing the tokens with the app client when the ID token is about to expire
+func (c *AppClient) IDTokenSource(token Token) func() (string, error) {
+	var mu sync.Mutex
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		exp, err := tokenExpiry(token.IDToken)
+		if err == nil && time.Until(exp) > DefaultIDTokenRefreshWindow {
+			return token.IDToken, nil
+		}
+		if token.RefreshToken == "" {
+			return "", errors.New("ID token expired and there is no refresh token")
+		}
+		refreshed, err := c.RefreshTokens(token.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		token = refreshed
+		return token.IDToken, nil
+	}
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it
+func tokenExpiry(t string) (time.Time, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(t, claims); err != nil {
+		return time.Time{}, err
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, errors.New("token has no exp claim")
+	}
+	return time.Unix(int64(exp), 0), nil
+}
@@ -0,0 +1,120 @@
+package cognito_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+// identityServer fakes the GetId and GetCredentialsForIdentity calls of cognito-identity
+type identityServer struct {
+	*httptest.Server
+	getIDCalls int
+	requests   []map[string]interface{}
+}
+
+func newIdentityServer(t *testing.T) *identityServer {
+	s := &identityServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&in))
+		s.requests = append(s.requests, in)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AWSCognitoIdentityService.GetId":
+			s.getIDCalls++
+			json.NewEncoder(w).Encode(map[string]interface{}{"IdentityId": "us-east-1:identity"})
+		case "AWSCognitoIdentityService.GetCredentialsForIdentity":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"IdentityId": in["IdentityId"],
+				"Credentials": map[string]interface{}{
+					"AccessKeyId":  "ASIATEST",
+					"SecretKey":    "secret",
+					"SessionToken": "session",
+					"Expiration":   time.Now().Add(time.Hour).Unix(),
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	return s
+}
+
+func TestIdentityPoolGetCredentials(t *testing.T) {
+	srv := newIdentityServer(t)
+	defer srv.Close()
+
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	pool := issuer.NewAppClient().NewIdentityPool("us-east-1:pool")
+	pool.Endpoint = srv.URL
+	pool.CustomRoleArn = "arn:aws:iam::123456789012:role/uploader"
+	assert.Equal(t, "cognito-idp.us-east-1.amazonaws.com/"+issuer.UserPoolID, pool.LoginKey())
+
+	creds, err := pool.GetCredentials("id-token")
+	assert.Nil(t, err)
+	assert.Equal(t, "us-east-1:identity", creds.IdentityID)
+	assert.Equal(t, "ASIATEST", creds.AccessKeyID)
+	assert.True(t, creds.Expiration.After(time.Now()))
+
+	assert.Len(t, srv.requests, 2)
+	assert.Equal(t, "us-east-1:pool", srv.requests[0]["IdentityPoolId"])
+	assert.Equal(t, map[string]interface{}{pool.LoginKey(): "id-token"}, srv.requests[0]["Logins"])
+	assert.Equal(t, pool.CustomRoleArn, srv.requests[1]["CustomRoleArn"])
+}
+
+func TestIdentityCredentialsProvider(t *testing.T) {
+	srv := newIdentityServer(t)
+	defer srv.Close()
+
+	pool := &cognito.IdentityPool{Region: "us-east-1", IdentityPoolID: "us-east-1:pool", UserPoolID: "pool", Endpoint: srv.URL}
+	creds := cognito.NewIdentityCredentials(pool, func() (string, error) { return "id-token", nil })
+
+	v, err := creds.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "ASIATEST", v.AccessKeyID)
+	assert.Equal(t, "session", v.SessionToken)
+	assert.Equal(t, cognito.IdentityCredentialsProviderName, v.ProviderName)
+
+	// Expired credentials are retrieved again without looking up the identity again
+	creds.Expire()
+	_, err = creds.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, srv.getIDCalls)
+	assert.Len(t, srv.requests, 3)
+}
+
+func TestIDTokenSourceRefreshes(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+	refreshed, err := issuer.MintIDToken(cognitotest.TokenOptions{Username: "jdoe"})
+	assert.Nil(t, err)
+	refreshes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		json.NewEncoder(w).Encode(map[string]interface{}{"id_token": refreshed, "expires_in": 3600})
+	}))
+	defer srv.Close()
+	client.TokenEndpoint = srv.URL
+
+	expiring, err := issuer.MintIDToken(cognitotest.TokenOptions{ExpiresAt: time.Now().Add(time.Minute)})
+	assert.Nil(t, err)
+	source := client.IDTokenSource(cognito.Token{IDToken: expiring, RefreshToken: "refresh"})
+
+	for i := 0; i < 2; i++ {
+		idToken, err := source()
+		assert.Nil(t, err)
+		assert.Equal(t, refreshed, idToken)
+	}
+	assert.Equal(t, 1, refreshes)
+
+	_, err = client.IDTokenSource(cognito.Token{IDToken: expiring})()
+	assert.NotNil(t, err)
+}
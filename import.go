@@ -0,0 +1,440 @@
+package cognito
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// DefaultImportPollInterval is how often WaitUserImportJob checks the status of a job
+const DefaultImportPollInterval = 10 * time.Second
+
+// Columns of the user import CSV that are not user attributes
+const (
+	importColumnUsername   = "cognito:username"
+	importColumnMFAEnabled = "cognito:mfa_enabled"
+)
+
+// ImportUser is a user to import with a user import job. Imported users have no
+// password, they must reset it with the forgot password flow on first sign in.
+type ImportUser struct {
+	// Username is required
+	Username            string
+	Email               string
+	EmailVerified       bool
+	PhoneNumber         string
+	PhoneNumberVerified bool
+	// MFAEnabled requires PhoneNumber, SMS MFA is enabled for the user
+	MFAEnabled bool
+	// Attributes are any other attributes of the pool's CSV header, custom attributes
+	// may be given without the "custom:" prefix
+	Attributes map[string]string
+}
+
+// ImportJob is the state of a user import job
+type ImportJob struct {
+	ID                    string    `json:"id"`
+	Name                  string    `json:"name"`
+	Status                string    `json:"status"`
+	PreSignedURL          string    `json:"-"`
+	CloudWatchLogsRoleArn string    `json:"cloudWatchLogsRoleArn"`
+	ImportedUsers         int64     `json:"importedUsers"`
+	SkippedUsers          int64     `json:"skippedUsers"`
+	FailedUsers           int64     `json:"failedUsers"`
+	CompletionMessage     string    `json:"completionMessage,omitempty"`
+	CreatedAt             time.Time `json:"createdAt"`
+	StartedAt             time.Time `json:"startedAt,omitempty"`
+	CompletedAt           time.Time `json:"completedAt,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not
+func (j *ImportJob) Done() bool {
+	switch j.Status {
+	case cognitoidentityprovider.UserImportJobStatusTypeSucceeded,
+		cognitoidentityprovider.UserImportJobStatusTypeFailed,
+		cognitoidentityprovider.UserImportJobStatusTypeStopped,
+		cognitoidentityprovider.UserImportJobStatusTypeExpired:
+		return true
+	}
+	return false
+}
+
+// LogGroup returns the CloudWatch log group Cognito writes the per user results of the
+// pool's import jobs to, the pool name is the one DescribeUserPool returns
+func (j *ImportJob) LogGroup(userPoolID, userPoolName string) string {
+	return "/aws/cognito/userpools/" + userPoolID + "/" + userPoolName
+}
+
+// LogStream returns the log stream of the job in its LogGroup
+func (j *ImportJob) LogStream() string {
+	return j.ID + "/" + j.Name
+}
+
+func newImportJob(t *cognitoidentityprovider.UserImportJobType) *ImportJob {
+	return &ImportJob{
+		ID:                    aws.StringValue(t.JobId),
+		Name:                  aws.StringValue(t.JobName),
+		Status:                aws.StringValue(t.Status),
+		PreSignedURL:          aws.StringValue(t.PreSignedUrl),
+		CloudWatchLogsRoleArn: aws.StringValue(t.CloudWatchLogsRoleArn),
+		ImportedUsers:         aws.Int64Value(t.ImportedUsers),
+		SkippedUsers:          aws.Int64Value(t.SkippedUsers),
+		FailedUsers:           aws.Int64Value(t.FailedUsers),
+		CompletionMessage:     aws.StringValue(t.CompletionMessage),
+		CreatedAt:             aws.TimeValue(t.CreationDate),
+		StartedAt:             aws.TimeValue(t.StartDate),
+		CompletedAt:           aws.TimeValue(t.CompletionDate),
+	}
+}
+
+// ImportJobError is returned by WaitUserImportJob for jobs that did not succeed or
+// that failed to import some users
+type ImportJobError struct {
+	Job *ImportJob
+	// Failures are the failure messages of the job's CloudWatch log, if they could be read
+	Failures []string
+	// LogError is why the failures could not be read
+	LogError error
+}
+
+func (e *ImportJobError) Error() string {
+	msg := fmt.Sprintf("user import job %s %s: %d imported, %d skipped, %d failed",
+		e.Job.ID, strings.ToLower(e.Job.Status), e.Job.ImportedUsers, e.Job.SkippedUsers, e.Job.FailedUsers)
+	if e.Job.CompletionMessage != "" {
+		msg += ": " + e.Job.CompletionMessage
+	}
+	switch {
+	case len(e.Failures) > 0:
+		msg += fmt.Sprintf(" (%d logged failures, first: %s)", len(e.Failures), e.Failures[0])
+	case e.LogError != nil:
+		msg += fmt.Sprintf(" (could not read the job log: %v)", e.LogError)
+	}
+	return msg
+}
+
+// WriteImportCSV writes the users as a user import CSV with the columns of header, which
+// must be the header GetCSVHeader returns for the pool
+func WriteImportCSV(w io.Writer, header []string, users []ImportUser) error {
+	columns := make(map[string]bool, len(header))
+	for _, col := range header {
+		columns[col] = true
+	}
+	if !columns[importColumnUsername] || !columns[importColumnMFAEnabled] {
+		return errors.New("header is not a user import CSV header")
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, u := range users {
+		row, err := importRow(header, columns, &u)
+		if err != nil {
+			return fmt.Errorf("user %d (%s): %v", i+1, u.Username, err)
+		}
+		if err = cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// importRow returns the CSV row of a user, checking the rules Cognito enforces on import
+func importRow(header []string, columns map[string]bool, u *ImportUser) ([]string, error) {
+	if u.Username == "" {
+		return nil, errors.New("username is required")
+	}
+	if u.MFAEnabled && u.PhoneNumber == "" {
+		return nil, errors.New("MFA needs a phone number")
+	}
+	if u.EmailVerified && u.Email == "" {
+		return nil, errors.New("email_verified without email")
+	}
+	if u.PhoneNumberVerified && u.PhoneNumber == "" {
+		return nil, errors.New("phone_number_verified without phone_number")
+	}
+
+	values := map[string]string{
+		importColumnUsername:    u.Username,
+		importColumnMFAEnabled:  strconv.FormatBool(u.MFAEnabled),
+		"email":                 u.Email,
+		"email_verified":        strconv.FormatBool(u.EmailVerified),
+		"phone_number":          u.PhoneNumber,
+		"phone_number_verified": strconv.FormatBool(u.PhoneNumberVerified),
+	}
+	for name, value := range u.Attributes {
+		col := AttributeName(name)
+		if !columns[col] {
+			return nil, fmt.Errorf("attribute %s is not a column of the pool's CSV header", col)
+		}
+		if _, ok := values[col]; ok {
+			return nil, fmt.Errorf("attribute %s must be set with its ImportUser field", col)
+		}
+		values[col] = value
+	}
+
+	row := make([]string, len(header))
+	for i, col := range header {
+		row[i] = values[col]
+	}
+	return row, nil
+}
+
+// GetCSVHeader returns the columns of the user import CSV of the pool
+// Requires a AWS session with developer credentials
+func (c *AppClient) GetCSVHeader() ([]string, error) {
+	input := &cognitoidentityprovider.GetCSVHeaderInput{
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.GetCSVHeader(input)
+	if err != nil {
+		return nil, err
+	}
+	return aws.StringValueSlice(out.CSVHeader), nil
+}
+
+// CreateUserImportJob creates a user import job, upload the CSV to its PreSignedURL with
+// UploadImportCSV. Cognito logs the results to CloudWatch with the role.
+// Requires a AWS session with developer credentials
+func (c *AppClient) CreateUserImportJob(name, cloudWatchLogsRoleArn string) (*ImportJob, error) {
+	input := &cognitoidentityprovider.CreateUserImportJobInput{
+		JobName:               aws.String(name),
+		CloudWatchLogsRoleArn: aws.String(cloudWatchLogsRoleArn),
+		UserPoolId:            &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.CreateUserImportJob(input)
+	if err != nil {
+		return nil, err
+	}
+	return newImportJob(out.UserImportJob), nil
+}
+
+// UploadImportCSV uploads the CSV to the pre-signed URL of an import job
+func UploadImportCSV(preSignedURL string, csv io.Reader) error {
+	b, err := ioutil.ReadAll(csv)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", preSignedURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	// The pre-signed URL is signed for this header
+	req.Header.Set("x-amz-server-side-encryption", "aws:kms")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("uploading import CSV: %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// StartUserImportJob starts an import job after its CSV was uploaded
+// Requires a AWS session with developer credentials
+func (c *AppClient) StartUserImportJob(jobID string) (*ImportJob, error) {
+	input := &cognitoidentityprovider.StartUserImportJobInput{
+		JobId:      aws.String(jobID),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.StartUserImportJob(input)
+	if err != nil {
+		return nil, err
+	}
+	return newImportJob(out.UserImportJob), nil
+}
+
+// StopUserImportJob stops a running import job
+// Requires a AWS session with developer credentials
+func (c *AppClient) StopUserImportJob(jobID string) (*ImportJob, error) {
+	input := &cognitoidentityprovider.StopUserImportJobInput{
+		JobId:      aws.String(jobID),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.StopUserImportJob(input)
+	if err != nil {
+		return nil, err
+	}
+	return newImportJob(out.UserImportJob), nil
+}
+
+// DescribeUserImportJob returns the current state of an import job
+// Requires a AWS session with developer credentials
+func (c *AppClient) DescribeUserImportJob(jobID string) (*ImportJob, error) {
+	input := &cognitoidentityprovider.DescribeUserImportJobInput{
+		JobId:      aws.String(jobID),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.DescribeUserImportJob(input)
+	if err != nil {
+		return nil, err
+	}
+	return newImportJob(out.UserImportJob), nil
+}
+
+// WaitUserImportJob polls the job every interval until it is done. If the job did not succeed
+// or failed to import users, an *ImportJobError with the failures of the CloudWatch log is returned.
+// Requires a AWS session with developer credentials and logs:FilterLogEvents for the failures
+func (c *AppClient) WaitUserImportJob(jobID string, interval time.Duration) (*ImportJob, error) {
+	job, err := waitImportJob(func() (*ImportJob, error) {
+		return c.DescribeUserImportJob(jobID)
+	}, interval, time.Sleep)
+	if ie, ok := err.(*ImportJobError); ok {
+		// The log only adds detail, the job error is returned even if it cannot be read
+		ie.Failures, ie.LogError = c.ImportJobFailures(ie.Job, 100)
+	}
+	return job, err
+}
+
+// waitImportJob polls describe until the job is done
+func waitImportJob(describe func() (*ImportJob, error), interval time.Duration, sleep func(time.Duration)) (*ImportJob, error) {
+	if interval <= 0 {
+		interval = DefaultImportPollInterval
+	}
+	for {
+		job, err := describe()
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			if job.Status != cognitoidentityprovider.UserImportJobStatusTypeSucceeded || job.FailedUsers > 0 {
+				return job, &ImportJobError{Job: job}
+			}
+			return job, nil
+		}
+		sleep(interval)
+	}
+}
+
+// ImportJobFailures returns up to limit failure messages of the job's CloudWatch log stream
+// Requires a AWS session with developer credentials and logs:FilterLogEvents on the pool's log group
+func (c *AppClient) ImportJobFailures(job *ImportJob, limit int) ([]string, error) {
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := cip.DescribeUserPool(&cognitoidentityprovider.DescribeUserPoolInput{
+		UserPoolId: &c.UserPoolID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ses, err := c.awsSession()
+	if err != nil {
+		return nil, err
+	}
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String(job.LogGroup(c.UserPoolID, aws.StringValue(pool.UserPool.Name))),
+		LogStreamNamePrefix: aws.String(job.ID),
+		FilterPattern:       aws.String("FAILED"),
+	}
+
+	var failures []string
+	err = cloudwatchlogs.New(ses).FilterLogEventsPages(input, func(out *cloudwatchlogs.FilterLogEventsOutput, last bool) bool {
+		for _, e := range out.Events {
+			if len(failures) == limit {
+				return false
+			}
+			failures = append(failures, aws.StringValue(e.Message))
+		}
+		return true
+	})
+	return failures, err
+}
+
+// ImportOptions controls ImportUsers
+type ImportOptions struct {
+	// JobName defaults to "import-<unix time>"
+	JobName string
+	// CloudWatchLogsRoleArn is the role Cognito logs the results with, required
+	CloudWatchLogsRoleArn string
+	// PollInterval defaults to DefaultImportPollInterval
+	PollInterval time.Duration
+}
+
+// ImportUsers imports the users with a user import job: it gets the pool's CSV header, writes
+// the CSV, creates the job, uploads the CSV, starts the job and waits for it. No invitation
+// messages are sent. Errors are returned like WaitUserImportJob returns them.
+// Requires a AWS session with developer credentials
+func (c *AppClient) ImportUsers(users []ImportUser, opts *ImportOptions) (*ImportJob, error) {
+	if opts == nil || opts.CloudWatchLogsRoleArn == "" {
+		return nil, errors.New("a CloudWatch logs role is required to import users")
+	}
+	header, err := c.GetCSVHeader()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = WriteImportCSV(&buf, header, users); err != nil {
+		return nil, err
+	}
+
+	name := opts.JobName
+	if name == "" {
+		name = fmt.Sprintf("import-%d", time.Now().Unix())
+	}
+	job, err := c.CreateUserImportJob(name, opts.CloudWatchLogsRoleArn)
+	if err != nil {
+		return nil, err
+	}
+	if err = UploadImportCSV(job.PreSignedURL, &buf); err != nil {
+		return job, err
+	}
+	if job, err = c.StartUserImportJob(job.ID); err != nil {
+		return job, err
+	}
+	return c.WaitUserImportJob(job.ID, opts.PollInterval)
+}
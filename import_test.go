@@ -0,0 +1,125 @@
+package cognito
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// importHeader is a GetCSVHeader result of a pool with a custom attribute
+var importHeader = []string{
+	"name", "given_name", "family_name", "email", "email_verified", "phone_number",
+	"phone_number_verified", "custom:tenant", "cognito:mfa_enabled", "cognito:username",
+}
+
+func TestWriteImportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteImportCSV(&buf, importHeader, []ImportUser{
+		{Username: "jdoe", Email: "jdoe@example.com", EmailVerified: true, Attributes: map[string]string{"name": "John Doe", "tenant": "acme"}},
+		{Username: "jane", PhoneNumber: "+15555550100", MFAEnabled: true, Attributes: map[string]string{"given_name": "Jane, Jr."}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"name,given_name,family_name,email,email_verified,phone_number,phone_number_verified,custom:tenant,cognito:mfa_enabled,cognito:username",
+		"John Doe,,,jdoe@example.com,true,,false,acme,false,jdoe",
+		`,"Jane, Jr.",,,false,+15555550100,false,,true,jane`,
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestWriteImportCSVValidates(t *testing.T) {
+	for name, u := range map[string]ImportUser{
+		"no username":            {},
+		"mfa without phone":      {Username: "a", MFAEnabled: true},
+		"verified without email": {Username: "a", EmailVerified: true},
+		"unknown attribute":      {Username: "a", Attributes: map[string]string{"plan": "pro"}},
+		"field as attribute":     {Username: "a", Attributes: map[string]string{"email": "a@example.com"}},
+	} {
+		err := WriteImportCSV(ioutil.Discard, importHeader, []ImportUser{u})
+		assert.NotNil(t, err, name)
+	}
+	assert.NotNil(t, WriteImportCSV(ioutil.Discard, []string{"email"}, nil), "Invalid header accepted")
+}
+
+func TestUploadImportCSV(t *testing.T) {
+	var body, sse string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		b, _ := ioutil.ReadAll(r.Body)
+		body, sse = string(b), r.Header.Get("x-amz-server-side-encryption")
+	}))
+	defer srv.Close()
+
+	assert.Nil(t, UploadImportCSV(srv.URL, strings.NewReader("a,b\n")))
+	assert.Equal(t, "a,b\n", body)
+	assert.Equal(t, "aws:kms", sse)
+}
+
+func TestWaitImportJob(t *testing.T) {
+	statuses := []string{"Pending", "InProgress", "Succeeded"}
+	sleeps := 0
+	job, err := waitImportJob(func() (*ImportJob, error) {
+		j := &ImportJob{ID: "import-1", Status: statuses[0], ImportedUsers: 2}
+		statuses = statuses[1:]
+		return j, nil
+	}, time.Second, func(time.Duration) { sleeps++ })
+	assert.Nil(t, err)
+	assert.Equal(t, "Succeeded", job.Status)
+	assert.Equal(t, 2, sleeps)
+
+	// Failed users make the job fail, even if its status is Succeeded
+	_, err = waitImportJob(func() (*ImportJob, error) {
+		return &ImportJob{ID: "import-2", Status: "Succeeded", ImportedUsers: 1, FailedUsers: 1}, nil
+	}, time.Second, func(time.Duration) {})
+	ie, ok := err.(*ImportJobError)
+	assert.True(t, ok)
+	assert.Equal(t, "user import job import-2 succeeded: 1 imported, 0 skipped, 1 failed", ie.Error())
+}
+
+func TestWaitUserImportJobReadsFailures(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("DescribeUserImportJob", func(in map[string]interface{}) (interface{}, string) {
+		return map[string]interface{}{"UserImportJob": map[string]interface{}{
+			"JobId": "import-1", "JobName": "nightly", "Status": "Succeeded", "ImportedUsers": 1, "FailedUsers": 2,
+		}}, ""
+	})
+	srv.handle("DescribeUserPool", func(in map[string]interface{}) (interface{}, string) {
+		return map[string]interface{}{"UserPool": map[string]interface{}{"Id": "us-east-1_Pool", "Name": "acme"}}, ""
+	})
+	srv.handle("Logs_20140328.FilterLogEvents", func(in map[string]interface{}) (interface{}, string) {
+		return map[string]interface{}{"events": []map[string]interface{}{
+			{"message": "FAILED alice: invalid email"},
+			{"message": "FAILED bob: invalid phone number"},
+		}}, ""
+	})
+
+	c := srv.client()
+	_, err := c.WaitUserImportJob("import-1", time.Millisecond)
+	ie, ok := err.(*ImportJobError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"FAILED alice: invalid email", "FAILED bob: invalid phone number"}, ie.Failures)
+	assert.Nil(t, ie.LogError)
+	assert.Equal(t, "user import job import-1 succeeded: 1 imported, 0 skipped, 2 failed (2 logged failures, first: FAILED alice: invalid email)", ie.Error())
+	filter := srv.calls[len(srv.calls)-1].Input
+	assert.Equal(t, "/aws/cognito/userpools/us-east-1_Pool/acme", filter["logGroupName"])
+	assert.Equal(t, "import-1", filter["logStreamNamePrefix"])
+	assert.Equal(t, "import-1/nightly", (&ImportJob{ID: "import-1", Name: "nightly"}).LogStream())
+
+	// The job error is returned with the reason the log could not be read
+	srv.handle("Logs_20140328.FilterLogEvents", func(in map[string]interface{}) (interface{}, string) {
+		return nil, "AccessDeniedException"
+	})
+	_, err = c.WaitUserImportJob("import-1", time.Millisecond)
+	ie, ok = err.(*ImportJobError)
+	assert.True(t, ok)
+	assert.Nil(t, ie.Failures)
+	assert.NotNil(t, ie.LogError)
+	assert.Contains(t, ie.Error(), "could not read the job log: AccessDeniedException")
+}
@@ -1,8 +1,12 @@
+//go:build integration
+// +build integration
+
 package cognito
 
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,19 +22,18 @@ var CFG = &AppClientConfig{}
 var cognitoID = ""
 
 func init() {
-	r, err := envy.MustGet("REGION")
-	p, err := envy.MustGet("POOL_ID")
-	c, err := envy.MustGet("CLIENT_ID")
+	// envy also reads a .env file, the variables without the COGNITO_ prefix still work
+	cfg, err := LoadConfig(&LoadConfigOptions{Getenv: func(key string) string {
+		return envy.Get(key, envy.Get(strings.TrimPrefix(key, ConfigEnvPrefix), ""))
+	}})
 	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
-
-	CFG.Region = r
-	CFG.PoolID = p
-	CFG.ClientID = c
+	CFG = cfg
 }
 
-// go test -run Integration
+// go test -tags integration -run Integration
 func TestIntegration(t *testing.T) {
 	t.Run("Integration Tests", func(t *testing.T) {
 		test := IntegrationTests{Test: t}
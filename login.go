@@ -0,0 +1,215 @@
+package cognito
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	b64 "encoding/base64"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultLoginAttemptTTL is how long a hosted UI login may take from redirect to callback
+const DefaultLoginAttemptTTL = 10 * time.Minute
+
+// Errors returned when completing a hosted UI login
+var (
+	ErrUnknownState        = errors.New("unknown or already used login state")
+	ErrStateMismatch       = errors.New("login state does not match")
+	ErrLoginAttemptExpired = errors.New("login attempt expired")
+	ErrNonceMismatch       = errors.New("ID token nonce does not match the login attempt")
+)
+
+// LoginAttempt ties a hosted UI login to the browser that started it. State is sent
+// with the redirect and checked on the callback, Nonce must come back in the ID token.
+type LoginAttempt struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	ReturnTo string    `json:"returnTo,omitempty"`
+	Created  time.Time `json:"created"`
+	// CodeVerifier is the PKCE secret of the login, see UsePKCE
+	CodeVerifier string `json:"codeVerifier,omitempty"`
+}
+
+// NewLoginAttempt generates a random state and nonce
+func NewLoginAttempt(returnTo string) (*LoginAttempt, error) {
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &LoginAttempt{State: state, Nonce: nonce, ReturnTo: returnTo, Created: time.Now()}, nil
+}
+
+// Expired reports whether the attempt is older than ttl
+func (a *LoginAttempt) Expired(ttl time.Duration) bool {
+	return time.Since(a.Created) > ttl
+}
+
+// UsePKCE generates a code verifier, so the login URL carries its challenge and the code
+// can only be exchanged with it. Public clients without a secret should always use PKCE.
+func (a *LoginAttempt) UsePKCE() error {
+	v, err := randomToken()
+	if err != nil {
+		return err
+	}
+	a.CodeVerifier = v
+	return nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of the code verifier
+func (a *LoginAttempt) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(a.CodeVerifier))
+	return b64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// LoginAttemptStore keeps login attempts between the redirect and the callback
+type LoginAttemptStore interface {
+	// Save stores the attempt under its state
+	Save(a *LoginAttempt) error
+	// Take returns and removes the attempt with the state, so it can only be used once.
+	// It returns nil if there is none. The store enforces how long attempts live,
+	// expired ones are not returned.
+	Take(state string) (*LoginAttempt, error)
+}
+
+// MemoryLoginAttemptStore is a LoginAttemptStore that keeps attempts in memory
+type MemoryLoginAttemptStore struct {
+	// TTL is how long attempts can be taken, defaults to DefaultLoginAttemptTTL
+	TTL time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempt
+}
+
+// NewMemoryLoginAttemptStore returns an empty MemoryLoginAttemptStore
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{TTL: DefaultLoginAttemptTTL, attempts: map[string]*LoginAttempt{}}
+}
+
+// Save implements LoginAttemptStore
+func (s *MemoryLoginAttemptStore) Save(a *LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attempts == nil {
+		s.attempts = map[string]*LoginAttempt{}
+	}
+	// Abandoned attempts are dropped here so the map cannot grow without bound
+	for state, old := range s.attempts {
+		if old.Expired(s.ttl()) {
+			delete(s.attempts, state)
+		}
+	}
+	s.attempts[a.State] = a
+	return nil
+}
+
+// Take implements LoginAttemptStore
+func (s *MemoryLoginAttemptStore) Take(state string) (*LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.attempts[state]
+	delete(s.attempts, state)
+	if a != nil && a.Expired(s.ttl()) {
+		return nil, ErrLoginAttemptExpired
+	}
+	return a, nil
+}
+
+func (s *MemoryLoginAttemptStore) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultLoginAttemptTTL
+	}
+	return s.TTL
+}
+
+// LoginURL returns the hosted UI login URL carrying the state, nonce and PKCE challenge of the attempt
+func (c *AppClient) LoginURL(a *LoginAttempt) string {
+	v := url.Values{}
+	v.Set("state", a.State)
+	v.Set("nonce", a.Nonce)
+	if a.CodeVerifier != "" {
+		v.Set("code_challenge", a.CodeChallenge())
+		v.Set("code_challenge_method", "S256")
+	}
+	return c.HostedLoginURL + "&" + v.Encode()
+}
+
+// BeginLogin creates and saves a login attempt and returns the URL to redirect the browser to.
+// It fails if the client has no HostedLoginURL, which is only set with the hosted UI domain.
+func (c *AppClient) BeginLogin(store LoginAttemptStore, returnTo string) (string, error) {
+	if c.HostedLoginURL == "" {
+		return "", errors.New("hosted login needs the hosted UI domain")
+	}
+	a, err := NewLoginAttempt(returnTo)
+	if err != nil {
+		return "", err
+	}
+	if err = store.Save(a); err != nil {
+		return "", err
+	}
+	return c.LoginURL(a), nil
+}
+
+// CompleteLogin takes the attempt of the callback's state from the store, so a state can only
+// be used once, and exchanges the code like ExchangeCode. The store decides when attempts expire.
+func (c *AppClient) CompleteLogin(store LoginAttemptStore, state, code string, scope []string) (*LoginAttempt, Token, *IDClaims, error) {
+	a, err := store.Take(state)
+	if err != nil {
+		return nil, Token{}, nil, err
+	}
+	if a == nil {
+		return nil, Token{}, nil, ErrUnknownState
+	}
+	token, claims, err := c.exchangeCode(a, state, code, scope)
+	return a, token, claims, err
+}
+
+// ExchangeCode checks that the attempt is not older than DefaultLoginAttemptTTL, then checks
+// the callback's state against it, exchanges the code for tokens and verifies the ID token
+// and its nonce. Use CompleteLogin for attempts kept in a LoginAttemptStore.
+func (c *AppClient) ExchangeCode(a *LoginAttempt, state, code string, scope []string) (Token, *IDClaims, error) {
+	if a.Expired(DefaultLoginAttemptTTL) {
+		return Token{}, nil, ErrLoginAttemptExpired
+	}
+	return c.exchangeCode(a, state, code, scope)
+}
+
+// exchangeCode is ExchangeCode without the expiry check
+func (c *AppClient) exchangeCode(a *LoginAttempt, state, code string, scope []string) (Token, *IDClaims, error) {
+	if subtle.ConstantTimeCompare([]byte(a.State), []byte(state)) != 1 {
+		return Token{}, nil, ErrStateMismatch
+	}
+
+	token, err := c.GetTokensPKCE(code, a.CodeVerifier, scope)
+	if err == nil && token.Error != "" {
+		err = errors.New("token endpoint error: " + token.Error)
+	}
+	if err != nil {
+		return Token{}, nil, err
+	}
+
+	claims, err := c.VerifyIDToken(token.IDToken)
+	if err != nil {
+		return Token{}, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(a.Nonce), []byte(claims.Nonce)) != 1 {
+		return Token{}, nil, ErrNonceMismatch
+	}
+
+	return token, claims, nil
+}
+
+// randomToken returns 32 random bytes, base64 URL encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,155 @@
+package cognito_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+// tokenServer returns a token endpoint that mints ID tokens with the nonce
+func tokenServer(issuer *cognitotest.Issuer, nonce *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idToken, _ := issuer.MintIDToken(cognitotest.TokenOptions{
+			Username: "jdoe",
+			Claims:   map[string]interface{}{"nonce": *nonce},
+		})
+		json.NewEncoder(w).Encode(map[string]interface{}{"id_token": idToken, "expires_in": 3600})
+	}))
+}
+
+func TestMemoryLoginAttemptStoreSingleUse(t *testing.T) {
+	store := cognito.NewMemoryLoginAttemptStore()
+	a, err := cognito.NewLoginAttempt("/home")
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.State, a.Nonce)
+	assert.Nil(t, store.Save(a))
+
+	got, err := store.Take(a.State)
+	assert.Nil(t, err)
+	assert.Equal(t, a, got)
+
+	got, err = store.Take(a.State)
+	assert.Nil(t, err)
+	assert.Nil(t, got, "Attempt returned twice")
+}
+
+func TestBeginAndCompleteLogin(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+	client.HostedLoginURL = "https://auth.example.com/login?response_type=code"
+	var nonce string
+	srv := tokenServer(issuer, &nonce)
+	defer srv.Close()
+	client.TokenEndpoint = srv.URL
+
+	store := cognito.NewMemoryLoginAttemptStore()
+	loginURL, err := client.BeginLogin(store, "/home")
+	assert.Nil(t, err)
+	u, err := url.Parse(loginURL)
+	assert.Nil(t, err)
+	state := u.Query().Get("state")
+	nonce = u.Query().Get("nonce")
+	assert.Equal(t, "code", u.Query().Get("response_type"))
+
+	a, _, claims, err := client.CompleteLogin(store, state, "code", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/home", a.ReturnTo)
+	assert.Equal(t, "jdoe", claims.Username)
+
+	_, _, _, err = client.CompleteLogin(store, state, "code", nil)
+	assert.Equal(t, cognito.ErrUnknownState, err)
+}
+
+func TestBeginLoginRequiresHostedUI(t *testing.T) {
+	client := cognitotest.MustNewIssuer("", "", "").NewAppClient()
+	client.HostedLoginURL = ""
+	store := cognito.NewMemoryLoginAttemptStore()
+
+	loginURL, err := client.BeginLogin(store, "/home")
+	assert.EqualError(t, err, "hosted login needs the hosted UI domain")
+	assert.Empty(t, loginURL)
+}
+
+func TestCompleteLoginUsesStoreTTL(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+	nonce := "nonce"
+	srv := tokenServer(issuer, &nonce)
+	defer srv.Close()
+	client.TokenEndpoint = srv.URL
+
+	// The store's TTL applies, not DefaultLoginAttemptTTL
+	store := cognito.NewMemoryLoginAttemptStore()
+	store.TTL = time.Hour
+	a := &cognito.LoginAttempt{State: "slow", Nonce: nonce, Created: time.Now().Add(-30 * time.Minute)}
+	assert.Nil(t, store.Save(a))
+	_, _, _, err := client.CompleteLogin(store, a.State, "code", nil)
+	assert.Nil(t, err)
+
+	store.TTL = time.Minute
+	a = &cognito.LoginAttempt{State: "expired", Nonce: nonce, Created: time.Now().Add(-30 * time.Second)}
+	assert.Nil(t, store.Save(a))
+	a.Created = time.Now().Add(-2 * time.Minute)
+	_, _, _, err = client.CompleteLogin(store, a.State, "code", nil)
+	assert.Equal(t, cognito.ErrLoginAttemptExpired, err)
+}
+
+func TestExchangeCodeChecks(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+	nonce := "other"
+	srv := tokenServer(issuer, &nonce)
+	defer srv.Close()
+	client.TokenEndpoint = srv.URL
+
+	a, err := cognito.NewLoginAttempt("")
+	assert.Nil(t, err)
+
+	_, _, err = client.ExchangeCode(a, "forged", "code", nil)
+	assert.Equal(t, cognito.ErrStateMismatch, err)
+
+	_, _, err = client.ExchangeCode(a, a.State, "code", nil)
+	assert.Equal(t, cognito.ErrNonceMismatch, err)
+
+	a.Created = time.Now().Add(-cognito.DefaultLoginAttemptTTL - time.Minute)
+	_, _, err = client.ExchangeCode(a, a.State, "code", nil)
+	assert.Equal(t, cognito.ErrLoginAttemptExpired, err)
+}
+
+func TestLoginWithPKCE(t *testing.T) {
+	issuer := cognitotest.MustNewIssuer("", "", "")
+	client := issuer.NewAppClient()
+	client.HostedLoginURL = "https://auth.example.com/login?response_type=code"
+	var verifier string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "Public client sent a basic authorization")
+		verifier = r.FormValue("code_verifier")
+		idToken, _ := issuer.MintIDToken(cognitotest.TokenOptions{Claims: map[string]interface{}{"nonce": r.FormValue("code")}})
+		json.NewEncoder(w).Encode(map[string]interface{}{"id_token": idToken, "expires_in": 3600})
+	}))
+	defer srv.Close()
+	client.TokenEndpoint = srv.URL
+
+	a, err := cognito.NewLoginAttempt("")
+	assert.Nil(t, err)
+	assert.Nil(t, a.UsePKCE())
+	u, err := url.Parse(client.LoginURL(a))
+	assert.Nil(t, err)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, a.CodeChallenge(), u.Query().Get("code_challenge"))
+	a.CodeVerifier = "dBjftJeZ4CVP-mJ92ZUhW7NsYh1oVCEfZCpRhHh-4Ik"
+	assert.Equal(t, "JuIh6QGAdEzCndrO2BTUalVEIRTkHS6EzWRpmEhhuXk", a.CodeChallenge())
+
+	// The test token endpoint puts the code into the nonce claim
+	_, _, err = client.ExchangeCode(a, a.State, a.Nonce, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, a.CodeVerifier, verifier)
+}
@@ -0,0 +1,227 @@
+package cognito
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// TOTP parameters used by Cognito software tokens
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+)
+
+// SoftwareToken is a TOTP secret associated with a user
+type SoftwareToken struct {
+	// SecretCode is the base32 encoded TOTP secret
+	SecretCode string
+	// Session is set when associating during the MFA_SETUP challenge,
+	// it must be passed to VerifySoftwareTokenWithSession
+	Session string
+	// ProvisioningURI is an otpauth:// URI for authenticator app QR codes
+	ProvisioningURI string
+}
+
+// MFAPreference enables an MFA method for a user and optionally makes it the preferred one
+type MFAPreference struct {
+	Enabled   bool
+	Preferred bool
+}
+
+// AssociateSoftwareToken generates a TOTP secret for the user the access token belongs to.
+// issuer and accountName label the entry in the authenticator app.
+func (c *AppClient) AssociateSoftwareToken(accessToken, issuer, accountName string) (*SoftwareToken, error) {
+	return c.associateSoftwareToken(&cognitoidentityprovider.AssociateSoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+	}, issuer, accountName)
+}
+
+// AssociateSoftwareTokenWithSession generates a TOTP secret during the MFA_SETUP challenge
+// of a user that has no access token yet
+func (c *AppClient) AssociateSoftwareTokenWithSession(session, issuer, accountName string) (*SoftwareToken, error) {
+	return c.associateSoftwareToken(&cognitoidentityprovider.AssociateSoftwareTokenInput{
+		Session: aws.String(session),
+	}, issuer, accountName)
+}
+
+func (c *AppClient) associateSoftwareToken(input *cognitoidentityprovider.AssociateSoftwareTokenInput, issuer, accountName string) (*SoftwareToken, error) {
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.AssociateSoftwareToken(input)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := aws.StringValue(out.SecretCode)
+	return &SoftwareToken{
+		SecretCode:      secret,
+		Session:         aws.StringValue(out.Session),
+		ProvisioningURI: TOTPProvisioningURI(issuer, accountName, secret),
+	}, nil
+}
+
+// VerifySoftwareToken verifies a TOTP code for the user the access token belongs to,
+// which completes the association of the software token
+func (c *AppClient) VerifySoftwareToken(accessToken, code, deviceName string) error {
+	input := &cognitoidentityprovider.VerifySoftwareTokenInput{
+		AccessToken: aws.String(accessToken),
+		UserCode:    aws.String(code),
+	}
+	if deviceName != "" {
+		input.FriendlyDeviceName = aws.String(deviceName)
+	}
+
+	_, err := c.verifySoftwareToken(input)
+	return err
+}
+
+// VerifySoftwareTokenWithSession verifies a TOTP code during the MFA_SETUP challenge and
+// returns the session to answer the challenge with
+func (c *AppClient) VerifySoftwareTokenWithSession(session, code, deviceName string) (string, error) {
+	input := &cognitoidentityprovider.VerifySoftwareTokenInput{
+		Session:  aws.String(session),
+		UserCode: aws.String(code),
+	}
+	if deviceName != "" {
+		input.FriendlyDeviceName = aws.String(deviceName)
+	}
+
+	out, err := c.verifySoftwareToken(input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Session), nil
+}
+
+func (c *AppClient) verifySoftwareToken(input *cognitoidentityprovider.VerifySoftwareTokenInput) (*cognitoidentityprovider.VerifySoftwareTokenOutput, error) {
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.VerifySoftwareToken(input)
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(out.Status) != cognitoidentityprovider.VerifySoftwareTokenResponseTypeSuccess {
+		return nil, fmt.Errorf("software token verification failed with status %s", aws.StringValue(out.Status))
+	}
+	return out, nil
+}
+
+// SetUserMFAPreference sets the SMS and TOTP MFA preferences of the user the access token
+// belongs to, a nil preference is left unchanged
+func (c *AppClient) SetUserMFAPreference(accessToken string, sms, totp *MFAPreference) error {
+	input := &cognitoidentityprovider.SetUserMFAPreferenceInput{
+		AccessToken:              aws.String(accessToken),
+		SMSMfaSettings:           smsMfaSettings(sms),
+		SoftwareTokenMfaSettings: softwareTokenMfaSettings(totp),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.SetUserMFAPreference(input)
+	return err
+}
+
+// AdminSetUserMFAPreference sets the SMS and TOTP MFA preferences of a user,
+// a nil preference is left unchanged
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminSetUserMFAPreference(username string, sms, totp *MFAPreference) error {
+	input := &cognitoidentityprovider.AdminSetUserMFAPreferenceInput{
+		Username:                 aws.String(username),
+		UserPoolId:               &c.UserPoolID,
+		SMSMfaSettings:           smsMfaSettings(sms),
+		SoftwareTokenMfaSettings: softwareTokenMfaSettings(totp),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.AdminSetUserMFAPreference(input)
+	return err
+}
+
+func smsMfaSettings(p *MFAPreference) *cognitoidentityprovider.SMSMfaSettingsType {
+	if p == nil {
+		return nil
+	}
+	return &cognitoidentityprovider.SMSMfaSettingsType{
+		Enabled:      aws.Bool(p.Enabled),
+		PreferredMfa: aws.Bool(p.Preferred),
+	}
+}
+
+func softwareTokenMfaSettings(p *MFAPreference) *cognitoidentityprovider.SoftwareTokenMfaSettingsType {
+	if p == nil {
+		return nil
+	}
+	return &cognitoidentityprovider.SoftwareTokenMfaSettingsType{
+		Enabled:      aws.Bool(p.Enabled),
+		PreferredMfa: aws.Bool(p.Preferred),
+	}
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI authenticator apps scan from a QR code
+func TOTPProvisioningURI(issuer, accountName, secret string) string {
+	label := url.PathEscape(accountName)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	v := url.Values{}
+	v.Set("secret", secret)
+	if issuer != "" {
+		v.Set("issuer", issuer)
+	}
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(TOTPDigits))
+	v.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// GenerateTOTP returns the RFC 6238 code for the base32 secret at time t,
+// e.g. to answer SOFTWARE_TOKEN_MFA challenges in automated tests
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(TOTPPeriod.Seconds())))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TOTPDigits, code%uint32(math.Pow10(TOTPDigits))), nil
+}
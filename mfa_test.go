@@ -0,0 +1,32 @@
+package cognito
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// RFC 6238 appendix B test vectors for SHA1, truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for ts, want := range tests {
+		code, err := GenerateTOTP(secret, time.Unix(ts, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, want, code, ts)
+	}
+
+	_, err := GenerateTOTP("not base32!", time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("My App", "jdoe@example.com", "ABCDEF")
+	assert.Equal(t, "otpauth://totp/My%20App:jdoe@example.com?algorithm=SHA1&digits=6&issuer=My+App&period=30&secret=ABCDEF", uri)
+}
@@ -0,0 +1,318 @@
+package cognito
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// OperationCategory is a Cognito quota category, the operations of a category share
+// a requests per second quota
+type OperationCategory string
+
+// Cognito quota categories
+const (
+	UserAuthentication     OperationCategory = "UserAuthentication"
+	UserCreation           OperationCategory = "UserCreation"
+	UserFederation         OperationCategory = "UserFederation"
+	UserAccountRecovery    OperationCategory = "UserAccountRecovery"
+	UserAccountRead        OperationCategory = "UserAccountRead"
+	UserAccountUpdate      OperationCategory = "UserAccountUpdate"
+	UserList               OperationCategory = "UserList"
+	UserResourceRead       OperationCategory = "UserResourceRead"
+	UserResourceUpdate     OperationCategory = "UserResourceUpdate"
+	UserPoolRead           OperationCategory = "UserPoolRead"
+	UserPoolUpdate         OperationCategory = "UserPoolUpdate"
+	UserPoolResourceRead   OperationCategory = "UserPoolResourceRead"
+	UserPoolResourceUpdate OperationCategory = "UserPoolResourceUpdate"
+	UserPoolClientRead     OperationCategory = "UserPoolClientRead"
+	UserPoolClientUpdate   OperationCategory = "UserPoolClientUpdate"
+)
+
+// DefaultRateLimits are Cognito's default quotas in requests per second. Quotas are per
+// account and region, lower them if other processes call the same pools.
+var DefaultRateLimits = map[OperationCategory]float64{
+	UserAuthentication:     120,
+	UserCreation:           50,
+	UserFederation:         25,
+	UserAccountRecovery:    30,
+	UserAccountRead:        120,
+	UserAccountUpdate:      25,
+	UserList:               30,
+	UserResourceRead:       50,
+	UserResourceUpdate:     25,
+	UserPoolRead:           15,
+	UserPoolUpdate:         15,
+	UserPoolResourceRead:   20,
+	UserPoolResourceUpdate: 15,
+	UserPoolClientRead:     15,
+	UserPoolClientUpdate:   15,
+}
+
+// operationCategories maps the Cognito operations to their quota category
+var operationCategories = map[string]OperationCategory{
+	"InitiateAuth":                UserAuthentication,
+	"RespondToAuthChallenge":      UserAuthentication,
+	"AdminInitiateAuth":           UserAuthentication,
+	"AdminRespondToAuthChallenge": UserAuthentication,
+
+	"SignUp":          UserCreation,
+	"AdminCreateUser": UserCreation,
+
+	"AdminLinkProviderForUser":    UserFederation,
+	"AdminDisableProviderForUser": UserFederation,
+
+	"ForgotPassword":         UserAccountRecovery,
+	"ConfirmForgotPassword":  UserAccountRecovery,
+	"ResendConfirmationCode": UserAccountRecovery,
+
+	"GetUser":      UserAccountRead,
+	"AdminGetUser": UserAccountRead,
+
+	"AdminConfirmSignUp":               UserAccountUpdate,
+	"ConfirmSignUp":                    UserAccountUpdate,
+	"AdminDeleteUser":                  UserAccountUpdate,
+	"DeleteUser":                       UserAccountUpdate,
+	"AdminDeleteUserAttributes":        UserAccountUpdate,
+	"DeleteUserAttributes":             UserAccountUpdate,
+	"AdminUpdateUserAttributes":        UserAccountUpdate,
+	"UpdateUserAttributes":             UserAccountUpdate,
+	"AdminSetUserPassword":             UserAccountUpdate,
+	"ChangePassword":                   UserAccountUpdate,
+	"AdminResetUserPassword":           UserAccountUpdate,
+	"AdminEnableUser":                  UserAccountUpdate,
+	"AdminDisableUser":                 UserAccountUpdate,
+	"AdminUserGlobalSignOut":           UserAccountUpdate,
+	"GlobalSignOut":                    UserAccountUpdate,
+	"AdminSetUserMFAPreference":        UserAccountUpdate,
+	"SetUserMFAPreference":             UserAccountUpdate,
+	"AssociateSoftwareToken":           UserAccountUpdate,
+	"VerifySoftwareToken":              UserAccountUpdate,
+	"GetUserAttributeVerificationCode": UserAccountUpdate,
+	"VerifyUserAttribute":              UserAccountUpdate,
+
+	"ListUsers":        UserList,
+	"ListUsersInGroup": UserList,
+
+	"AdminListGroupsForUser": UserResourceRead,
+	"AdminGetDevice":         UserResourceRead,
+	"GetDevice":              UserResourceRead,
+	"AdminListDevices":       UserResourceRead,
+	"ListDevices":            UserResourceRead,
+
+	"AdminAddUserToGroup":      UserResourceUpdate,
+	"AdminRemoveUserFromGroup": UserResourceUpdate,
+	"ConfirmDevice":            UserResourceUpdate,
+	"UpdateDeviceStatus":       UserResourceUpdate,
+	"AdminUpdateDeviceStatus":  UserResourceUpdate,
+	"ForgetDevice":             UserResourceUpdate,
+	"AdminForgetDevice":        UserResourceUpdate,
+
+	"DescribeUserPool": UserPoolRead,
+	"ListUserPools":    UserPoolRead,
+
+	"GetGroup":              UserPoolResourceRead,
+	"ListGroups":            UserPoolResourceRead,
+	"GetCSVHeader":          UserPoolResourceRead,
+	"DescribeUserImportJob": UserPoolResourceRead,
+	"ListUserImportJobs":    UserPoolResourceRead,
+
+	"CreateGroup":         UserPoolResourceUpdate,
+	"UpdateGroup":         UserPoolResourceUpdate,
+	"DeleteGroup":         UserPoolResourceUpdate,
+	"CreateUserImportJob": UserPoolResourceUpdate,
+	"StartUserImportJob":  UserPoolResourceUpdate,
+	"StopUserImportJob":   UserPoolResourceUpdate,
+
+	"DescribeUserPoolClient": UserPoolClientRead,
+	"ListUserPoolClients":    UserPoolClientRead,
+
+	"UpdateUserPoolClient": UserPoolClientUpdate,
+}
+
+// CategoryOf returns the quota category of a Cognito operation, empty if it is not known
+func CategoryOf(operation string) OperationCategory {
+	return operationCategories[operation]
+}
+
+// Hooks are called for the Cognito calls of an AppClient, e.g. to log or count throttling.
+// Hooks are called concurrently if the client is used concurrently.
+type Hooks struct {
+	// OnRateLimited is called when a call had to wait for the rate limiter
+	OnRateLimited func(operation string, category OperationCategory, wait time.Duration)
+	// OnRetry is called before a throttled call is retried, retry counts from 1
+	OnRetry func(operation string, retry int, delay time.Duration, err error)
+	// OnComplete is called when a call is done, with the number of retries it took
+	OnComplete func(operation string, retries int, err error)
+}
+
+// RateLimiter is a client-side token bucket per quota category. It is safe for concurrent
+// use and can be shared by clients of the same account and region.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rates   map[OperationCategory]float64
+	buckets map[OperationCategory]*tokenBucket
+}
+
+// NewRateLimiter returns a limiter with DefaultRateLimits, overridden by rates.
+// A rate of 0 or less turns off the limit of the category.
+func NewRateLimiter(rates map[OperationCategory]float64) *RateLimiter {
+	l := &RateLimiter{
+		rates:   map[OperationCategory]float64{},
+		buckets: map[OperationCategory]*tokenBucket{},
+	}
+	for cat, rate := range DefaultRateLimits {
+		l.rates[cat] = rate
+	}
+	for cat, rate := range rates {
+		l.rates[cat] = rate
+	}
+	return l
+}
+
+// Wait blocks until the operation is allowed by the quota of its category and returns
+// how long it waited. Operations without a known category are not limited.
+func (l *RateLimiter) Wait(operation string) time.Duration {
+	b := l.bucket(CategoryOf(operation))
+	if b == nil {
+		return 0
+	}
+	return b.wait()
+}
+
+func (l *RateLimiter) bucket(cat OperationCategory) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[cat]; ok {
+		return b
+	}
+	rate := l.rates[cat]
+	if rate <= 0 {
+		return nil
+	}
+	// Allow a burst of one second of the quota
+	b := newTokenBucket(rate, rate)
+	l.buckets[cat] = b
+	return b
+}
+
+// tokenBucket refills rate tokens per second up to burst, every call takes a token
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, now: time.Now}
+}
+
+// reserve takes a token and returns how long to wait until it is available. Tokens can
+// be taken ahead, so concurrent callers wait in turn.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is available
+func (b *tokenBucket) wait() time.Duration {
+	d := b.reserve()
+	time.Sleep(d)
+	return d
+}
+
+// throttleRetryer retries throttled calls with jittered exponential backoff, other
+// errors are retried like the SDK does
+type throttleRetryer struct {
+	client.DefaultRetryer
+	base  time.Duration
+	hooks *Hooks
+}
+
+// ShouldRetry implements request.Retryer
+func (r throttleRetryer) ShouldRetry(req *request.Request) bool {
+	return IsThrottlingError(req.Error) || r.DefaultRetryer.ShouldRetry(req)
+}
+
+// RetryRules implements request.Retryer
+func (r throttleRetryer) RetryRules(req *request.Request) time.Duration {
+	if !IsThrottlingError(req.Error) {
+		return r.DefaultRetryer.RetryRules(req)
+	}
+	delay := backoff(req.RetryCount, r.base)
+	if r.hooks != nil && r.hooks.OnRetry != nil {
+		r.hooks.OnRetry(req.Operation.Name, req.RetryCount+1, delay, req.Error)
+	}
+	return delay
+}
+
+// throttle adds the client's rate limiter, retries and hooks to an AWS service client
+func (c *AppClient) throttle(cl *client.Client) {
+	cl.Retryer = c.retryer(c.MaxRetries, DefaultRetryBaseDelay)
+
+	limiter, hooks := c.RateLimiter, c.Hooks
+	if limiter != nil {
+		// Sign runs for every attempt, so retries take a token too
+		cl.Handlers.Sign.PushFront(func(r *request.Request) {
+			if wait := limiter.Wait(r.Operation.Name); wait > 0 && hooks != nil && hooks.OnRateLimited != nil {
+				hooks.OnRateLimited(r.Operation.Name, CategoryOf(r.Operation.Name), wait)
+			}
+		})
+	}
+	if hooks != nil && hooks.OnComplete != nil {
+		cl.Handlers.Complete.PushBack(func(r *request.Request) {
+			hooks.OnComplete(r.Operation.Name, r.RetryCount, r.Error)
+		})
+	}
+}
+
+// retryer returns the retryer of throttled calls. maxRetries is DefaultMaxRetries if 0
+// and none if negative, base defaults to DefaultRetryBaseDelay.
+func (c *AppClient) retryer(maxRetries int, base time.Duration) throttleRetryer {
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	return throttleRetryer{
+		DefaultRetryer: client.DefaultRetryer{NumMaxRetries: maxRetries},
+		base:           base,
+		hooks:          c.Hooks,
+	}
+}
+
+// withRetries is a request option that retries the call with its own retry settings, like
+// retryer, instead of the client's, and adds the retries the call took to retries
+func (c *AppClient) withRetries(maxRetries int, base time.Duration, retries *int) request.Option {
+	retryer := c.retryer(maxRetries, base)
+	return func(r *request.Request) {
+		r.Retryer = retryer
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			*retries += r.RetryCount
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package cognito
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryOf(t *testing.T) {
+	assert.Equal(t, UserResourceUpdate, CategoryOf("AdminAddUserToGroup"))
+	assert.Equal(t, UserCreation, CategoryOf("SignUp"))
+	assert.Equal(t, UserAccountUpdate, CategoryOf("AdminDeleteUser"))
+	assert.Equal(t, OperationCategory(""), CategoryOf("TagResource"))
+	for _, cat := range operationCategories {
+		assert.NotZero(t, DefaultRateLimits[cat], "No default rate for %s", cat)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+
+	// The burst is available at once, then calls are spaced by the rate
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, 100*time.Millisecond, b.reserve())
+	assert.Equal(t, 200*time.Millisecond, b.reserve())
+
+	// Refills up to the burst only
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, 100*time.Millisecond, b.reserve())
+}
+
+func TestRateLimiterOverrides(t *testing.T) {
+	l := NewRateLimiter(map[OperationCategory]float64{UserList: 0, UserCreation: 1})
+	assert.Nil(t, l.bucket(UserList))
+	assert.Nil(t, l.bucket(""))
+	assert.Equal(t, 1.0, l.bucket(UserCreation).rate)
+	assert.Equal(t, 25.0, l.bucket(UserResourceUpdate).rate)
+	assert.Equal(t, time.Duration(0), l.Wait("ListUsers"))
+}
+
+func TestThrottledCallsAreRetried(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"TooManyRequestsException","message":"Too many requests"}`))
+			return
+		}
+		w.Write([]byte(`{"Username":"jdoe"}`))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var retries []int
+	var completed int
+	c := &AppClient{
+		Region:      "us-east-1",
+		RateLimiter: NewRateLimiter(nil),
+		Hooks: &Hooks{
+			OnRetry: func(operation string, retry int, delay time.Duration, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				assert.Equal(t, "AdminGetUser", operation)
+				assert.True(t, IsThrottlingError(err))
+				retries = append(retries, retry)
+			},
+			OnComplete: func(operation string, n int, err error) {
+				assert.Nil(t, err)
+				completed = n
+			},
+		},
+	}
+	ses := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(c.Region),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	cip := cognitoidentityprovider.New(ses)
+	c.throttle(cip.Client)
+
+	out, err := cip.AdminGetUser(&cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String("us-east-1_pool"),
+		Username:   aws.String("jdoe"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "jdoe", aws.StringValue(out.Username))
+	assert.Equal(t, []int{1, 2}, retries)
+	assert.Equal(t, 2, completed)
+
+	// Without retries the throttling error is returned
+	calls = 0
+	c.MaxRetries = -1
+	c.Hooks = nil
+	cip = cognitoidentityprovider.New(ses)
+	c.throttle(cip.Client)
+	_, err = cip.AdminGetUser(&cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String("us-east-1_pool"),
+		Username:   aws.String("jdoe"),
+	})
+	assert.True(t, IsThrottlingError(err))
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,36 @@
+package cognito
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// Defaults for retrying throttled Cognito calls
+const (
+	DefaultMaxRetries     = 5
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	DefaultRetryMaxDelay  = 10 * time.Second
+)
+
+// IsThrottlingError reports whether err is Cognito rejecting a request for exceeding its quota
+func IsThrottlingError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case cognitoidentityprovider.ErrCodeTooManyRequestsException, "ThrottlingException":
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns a random delay in [0, base * 2^attempt), capped at DefaultRetryMaxDelay
+func backoff(attempt int, base time.Duration) time.Duration {
+	max := base << uint(attempt)
+	if max <= 0 || max > DefaultRetryMaxDelay {
+		max = DefaultRetryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
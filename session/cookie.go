@@ -0,0 +1,151 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookies are split into chunks to stay below the 4096 byte limit of browsers,
+// three Cognito tokens usually need two chunks
+const (
+	maxChunkSize = 3800
+	maxChunks    = 5
+)
+
+// errNoCookie is returned when the cookie is not in the request
+var errNoCookie = errors.New("cookie not found")
+
+// codec encrypts and authenticates cookie values with AES-GCM. The cookie name is
+// bound as additional data, so a value cannot be moved to another cookie.
+type codec struct {
+	aead cipher.AEAD
+}
+
+func newCodec(key []byte) (*codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie key: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &codec{aead: aead}, nil
+}
+
+// encode serializes v as JSON and seals it for the named cookie
+func (c *codec) encode(name string, v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, b, []byte(name))
+	return b64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decode opens a value sealed by encode for the named cookie into v
+func (c *codec) decode(name, value string, v interface{}) error {
+	sealed, err := b64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return errors.New("cookie value too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	b, err := c.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return errors.New("cookie value could not be authenticated")
+	}
+	return json.Unmarshal(b, v)
+}
+
+// cookieOptions are the attributes of the cookies set by the manager
+type cookieOptions struct {
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func (o cookieOptions) cookie(name, value string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   o.Secure,
+		HttpOnly: true,
+		SameSite: o.SameSite,
+	}
+}
+
+// chunkName returns the name of the i-th chunk of a cookie
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s_%d", name, i)
+}
+
+// writeChunked sets value in as many chunks as needed and expires any
+// chunks of a previous, longer value that the request still carries
+func writeChunked(w http.ResponseWriter, r *http.Request, o cookieOptions, name, value string, maxAge time.Duration) error {
+	var chunks []string
+	for len(value) > maxChunkSize {
+		chunks = append(chunks, value[:maxChunkSize])
+		value = value[maxChunkSize:]
+	}
+	chunks = append(chunks, value)
+	if len(chunks) > maxChunks {
+		return fmt.Errorf("cookie %s needs %d chunks, at most %d are allowed", name, len(chunks), maxChunks)
+	}
+
+	for i, chunk := range chunks {
+		http.SetCookie(w, o.cookie(chunkName(name, i), chunk, maxAge))
+	}
+	for i := len(chunks); i < maxChunks; i++ {
+		if _, err := r.Cookie(chunkName(name, i)); err == nil {
+			http.SetCookie(w, o.cookie(chunkName(name, i), "", -time.Second))
+		}
+	}
+	return nil
+}
+
+// readChunked joins the chunks of a cookie
+func readChunked(r *http.Request, name string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < maxChunks; i++ {
+		c, err := r.Cookie(chunkName(name, i))
+		if err != nil {
+			break
+		}
+		sb.WriteString(c.Value)
+	}
+	if sb.Len() == 0 {
+		return "", errNoCookie
+	}
+	return sb.String(), nil
+}
+
+// clearChunked expires all chunks of a cookie the request carries
+func clearChunked(w http.ResponseWriter, r *http.Request, o cookieOptions, name string) {
+	for i := 0; i < maxChunks; i++ {
+		if _, err := r.Cookie(chunkName(name, i)); err == nil {
+			http.SetCookie(w, o.cookie(chunkName(name, i), "", -time.Second))
+		}
+	}
+}
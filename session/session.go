@@ -0,0 +1,410 @@
+// Package session manages browser sessions of users signed in through the
+// Cognito hosted UI.
+//
+// The Manager provides the /login, /callback and /logout handlers of the
+// authorization code flow and stores the tokens in encrypted cookies, or in a
+// server side Store. Expired tokens are refreshed transparently and the
+// RequireLogin middleware sends unauthenticated browsers to the login page.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	b64 "encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joescharf/cognito"
+)
+
+// Defaults for Config
+const (
+	DefaultCookieName    = "cognito_session"
+	DefaultMaxAge        = 30 * 24 * time.Hour
+	DefaultRefreshMargin = time.Minute
+	DefaultLoginPath     = "/login"
+)
+
+// Errors returned by Manager.Load
+var (
+	ErrNoSession      = errors.New("no session")
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// Config configures a Manager
+type Config struct {
+	// Client is the app client of the hosted UI, with Domain and RedirectURI set. Required.
+	Client *cognito.AppClient
+	// Key encrypts and authenticates the cookies with AES-GCM, 16, 24 or 32 bytes. Required.
+	Key []byte
+	// Store keeps the tokens on the server, by default they are stored in the cookie
+	Store Store
+	// AttemptStore keeps the state and nonce of pending logins on the server, so each can be
+	// used only once, the browser only keeps the state in a cookie. By default the whole attempt
+	// is kept in a short lived cookie that the callback clears.
+	AttemptStore cognito.LoginAttemptStore
+	// CookieName defaults to DefaultCookieName
+	CookieName string
+	// CookiePath defaults to "/"
+	CookiePath   string
+	CookieDomain string
+	// Insecure allows the cookies over plain http, for local development only
+	Insecure bool
+	// SameSite defaults to http.SameSiteLaxMode, which the redirect from the hosted UI needs
+	SameSite http.SameSite
+	// MaxAge is the lifetime of a session, it should not exceed the refresh token validity.
+	// Defaults to DefaultMaxAge.
+	MaxAge time.Duration
+	// RefreshMargin refreshes tokens this long before they expire, defaults to DefaultRefreshMargin
+	RefreshMargin time.Duration
+	// Scopes are requested when exchanging the code
+	Scopes []string
+	// LoginPath is where the LoginHandler is mounted, defaults to DefaultLoginPath
+	LoginPath string
+	// AfterLoginURL is where users land after login if they did not come from a page, defaults to "/"
+	AfterLoginURL string
+	// LogoutURL is where users are sent after logout, defaults to the hosted UI logout URL
+	LogoutURL string
+	// ErrorHandler writes the response for failed logins, defaults to http.Error
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error, status int)
+}
+
+// Data is a signed in user's session
+type Data struct {
+	Token   cognito.Token `json:"token"`
+	Expiry  time.Time     `json:"expiry"`
+	Created time.Time     `json:"created"`
+	// Claims are verified from the ID token on every load
+	Claims *cognito.IDClaims `json:"-"`
+}
+
+// Manager handles login, logout and the sessions of signed in users
+type Manager struct {
+	cfg     Config
+	codec   *codec
+	cookies cookieOptions
+}
+
+type contextKey struct{}
+
+// NewManager returns a Manager for the config
+func NewManager(cfg Config) (*Manager, error) {
+	if cfg.Client == nil {
+		return nil, errors.New("session: Client is required")
+	}
+	codec, err := newCodec(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCookieName
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultMaxAge
+	}
+	if cfg.RefreshMargin <= 0 {
+		cfg.RefreshMargin = DefaultRefreshMargin
+	}
+	if cfg.LoginPath == "" {
+		cfg.LoginPath = DefaultLoginPath
+	}
+	if cfg.AfterLoginURL == "" {
+		cfg.AfterLoginURL = "/"
+	}
+	if cfg.LogoutURL == "" {
+		cfg.LogoutURL = cfg.Client.HostedLogoutURL
+	}
+	if cfg.LogoutURL == "" {
+		cfg.LogoutURL = "/"
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error, status int) {
+			http.Error(w, http.StatusText(status), status)
+		}
+	}
+
+	return &Manager{
+		cfg:   cfg,
+		codec: codec,
+		cookies: cookieOptions{
+			Path:     cfg.CookiePath,
+			Domain:   cfg.CookieDomain,
+			Secure:   !cfg.Insecure,
+			SameSite: cfg.SameSite,
+		},
+	}, nil
+}
+
+// FromContext returns the session RequireLogin added to the request context
+func FromContext(ctx context.Context) *Data {
+	d, _ := ctx.Value(contextKey{}).(*Data)
+	return d
+}
+
+// LoginHandler redirects to the hosted UI login page with a fresh state and nonce.
+// A relative return_to query parameter is where the user lands after the callback.
+func (m *Manager) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a, err := cognito.NewLoginAttempt(safeReturnTo(r.URL.Query().Get("return_to")))
+		if err != nil {
+			m.cfg.ErrorHandler(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		// The login cookie ties the attempt to this browser, with a store it only holds the state
+		if m.cfg.AttemptStore != nil {
+			if err = m.cfg.AttemptStore.Save(a); err == nil {
+				err = m.writeCookie(w, r, m.attemptCookieName(), a.State, cognito.DefaultLoginAttemptTTL)
+			}
+		} else {
+			err = m.writeCookie(w, r, m.attemptCookieName(), a, cognito.DefaultLoginAttemptTTL)
+		}
+		if err != nil {
+			m.cfg.ErrorHandler(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, m.cfg.Client.LoginURL(a), http.StatusFound)
+	})
+}
+
+// CallbackHandler handles the redirect from the hosted UI. It checks the state against the
+// login attempt of this browser, exchanges the code for tokens, verifies them and the nonce
+// and starts the session.
+func (m *Manager) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			m.cfg.ErrorHandler(w, r, errors.New("hosted UI error: "+e+" "+q.Get("error_description")), http.StatusUnauthorized)
+			return
+		}
+
+		a, token, err := m.complete(w, r, q.Get("state"), q.Get("code"))
+		switch err {
+		case nil:
+		case cognito.ErrUnknownState, cognito.ErrStateMismatch, cognito.ErrLoginAttemptExpired:
+			m.cfg.ErrorHandler(w, r, err, http.StatusBadRequest)
+			return
+		default:
+			m.cfg.ErrorHandler(w, r, err, http.StatusUnauthorized)
+			return
+		}
+
+		if err = m.start(w, r, token); err != nil {
+			m.cfg.ErrorHandler(w, r, err, http.StatusUnauthorized)
+			return
+		}
+
+		returnTo := a.ReturnTo
+		if returnTo == "" {
+			returnTo = m.cfg.AfterLoginURL
+		}
+		http.Redirect(w, r, returnTo, http.StatusFound)
+	})
+}
+
+// complete takes the login attempt of the callback, so it cannot be reused, and exchanges
+// the code. The state must match the login cookie of the browser, so a callback started
+// elsewhere cannot sign it in. Attempts in the AttemptStore expire as the store decides.
+func (m *Manager) complete(w http.ResponseWriter, r *http.Request, state, code string) (*cognito.LoginAttempt, cognito.Token, error) {
+	defer clearChunked(w, r, m.cookies, m.attemptCookieName())
+
+	if m.cfg.AttemptStore != nil {
+		var browserState string
+		if err := m.readCookie(r, m.attemptCookieName(), &browserState); err != nil {
+			return nil, cognito.Token{}, cognito.ErrUnknownState
+		}
+		if subtle.ConstantTimeCompare([]byte(browserState), []byte(state)) != 1 {
+			return nil, cognito.Token{}, cognito.ErrStateMismatch
+		}
+		a, token, _, err := m.cfg.Client.CompleteLogin(m.cfg.AttemptStore, state, code, m.cfg.Scopes)
+		return a, token, err
+	}
+
+	a := &cognito.LoginAttempt{}
+	if err := m.readCookie(r, m.attemptCookieName(), a); err != nil {
+		return nil, cognito.Token{}, cognito.ErrUnknownState
+	}
+	token, _, err := m.cfg.Client.ExchangeCode(a, state, code, m.cfg.Scopes)
+	return a, token, err
+}
+
+// LogoutHandler ends the session and redirects to the LogoutURL
+func (m *Manager) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Clear(w, r)
+		http.Redirect(w, r, m.cfg.LogoutURL, http.StatusFound)
+	})
+}
+
+// RequireLogin only calls next for signed in users, with the session in the request context.
+// Browsers are redirected to the login page, other clients get 401 Unauthorized.
+func (m *Manager) RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := m.Load(w, r)
+		if err != nil {
+			if isBrowser(r) {
+				http.Redirect(w, r, m.cfg.LoginPath+"?return_to="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, d)))
+	})
+}
+
+// Load returns the session of the request, refreshing the tokens if they are about to expire.
+// The ID token is verified on every load.
+func (m *Manager) Load(w http.ResponseWriter, r *http.Request) (*Data, error) {
+	id, d, err := m.read(r)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(d.Created) > m.cfg.MaxAge {
+		m.Clear(w, r)
+		return nil, ErrSessionExpired
+	}
+
+	if time.Now().Add(m.cfg.RefreshMargin).After(d.Expiry) {
+		token, err := m.cfg.Client.RefreshTokens(d.Token.RefreshToken)
+		if err != nil {
+			m.Clear(w, r)
+			return nil, ErrSessionExpired
+		}
+		d.Token = token
+		d.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		if err = m.write(w, r, id, d); err != nil {
+			return nil, err
+		}
+	}
+
+	d.Claims, err = m.cfg.Client.VerifyIDToken(d.Token.IDToken)
+	if err != nil {
+		m.Clear(w, r)
+		return nil, err
+	}
+	return d, nil
+}
+
+// Clear ends the session of the request
+func (m *Manager) Clear(w http.ResponseWriter, r *http.Request) {
+	if m.cfg.Store != nil {
+		var id string
+		if err := m.readCookie(r, m.cfg.CookieName, &id); err == nil {
+			m.cfg.Store.Delete(id)
+		}
+	}
+	clearChunked(w, r, m.cookies, m.cfg.CookieName)
+}
+
+// start saves a new session for verified tokens
+func (m *Manager) start(w http.ResponseWriter, r *http.Request, token cognito.Token) error {
+	// A new id on every login prevents session fixation
+	var id string
+	if m.cfg.Store != nil {
+		m.Clear(w, r)
+		var err error
+		if id, err = randomString(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	return m.write(w, r, id, &Data{
+		Token:   token,
+		Expiry:  now.Add(time.Duration(token.ExpiresIn) * time.Second),
+		Created: now,
+	})
+}
+
+// read loads the session id and data from the cookie and, if configured, the store
+func (m *Manager) read(r *http.Request) (string, *Data, error) {
+	if m.cfg.Store == nil {
+		d := &Data{}
+		if err := m.readCookie(r, m.cfg.CookieName, d); err != nil {
+			return "", nil, ErrNoSession
+		}
+		return "", d, nil
+	}
+
+	var id string
+	if err := m.readCookie(r, m.cfg.CookieName, &id); err != nil {
+		return "", nil, ErrNoSession
+	}
+	stored, err := m.cfg.Store.Get(id)
+	if err != nil {
+		return "", nil, err
+	}
+	if stored == nil {
+		return "", nil, ErrNoSession
+	}
+	// Copy, the store may share the value between requests
+	d := *stored
+	return id, &d, nil
+}
+
+// write saves the session to the cookie and, if configured, the store
+func (m *Manager) write(w http.ResponseWriter, r *http.Request, id string, d *Data) error {
+	maxAge := m.cfg.MaxAge - time.Since(d.Created)
+	if m.cfg.Store == nil {
+		return m.writeCookie(w, r, m.cfg.CookieName, d, maxAge)
+	}
+
+	if err := m.cfg.Store.Save(id, d, maxAge); err != nil {
+		return err
+	}
+	return m.writeCookie(w, r, m.cfg.CookieName, id, maxAge)
+}
+
+func (m *Manager) writeCookie(w http.ResponseWriter, r *http.Request, name string, v interface{}, maxAge time.Duration) error {
+	value, err := m.codec.encode(name, v)
+	if err != nil {
+		return err
+	}
+	return writeChunked(w, r, m.cookies, name, value, maxAge)
+}
+
+func (m *Manager) readCookie(r *http.Request, name string, v interface{}) error {
+	value, err := readChunked(r, name)
+	if err != nil {
+		return err
+	}
+	return m.codec.decode(name, value, v)
+}
+
+func (m *Manager) attemptCookieName() string {
+	return m.cfg.CookieName + "_login"
+}
+
+// safeReturnTo only allows local paths to prevent open redirects
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return ""
+	}
+	return returnTo
+}
+
+// isBrowser reports whether the request is a page navigation that can follow a redirect to the login page
+func isBrowser(r *http.Request) bool {
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,263 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+// testEnv is a hosted UI token endpoint backed by a cognitotest issuer
+type testEnv struct {
+	server    *httptest.Server
+	issuer    *cognitotest.Issuer
+	client    *cognito.AppClient
+	expiresIn int
+	refreshes int
+	// nonce is put into minted ID tokens, as the hosted UI does with the nonce of the login URL
+	nonce string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{issuer: cognitotest.MustNewIssuer("", "", ""), expiresIn: 3600}
+	env.client = env.issuer.NewAppClient()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		if r.Form.Get("grant_type") == "refresh_token" {
+			env.refreshes++
+		} else if r.Form.Get("code") != "good-code" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idToken, _ := env.issuer.MintIDToken(cognitotest.TokenOptions{
+			Username: "jdoe",
+			Claims:   map[string]interface{}{"nonce": env.nonce},
+		})
+		accessToken, _ := env.issuer.MintAccessToken(cognitotest.TokenOptions{Username: "jdoe"})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id_token":      idToken,
+			"access_token":  accessToken,
+			"refresh_token": "refresh-token",
+			"expires_in":    env.expiresIn,
+			"token_type":    "Bearer",
+		})
+	}))
+	env.server = srv
+
+	env.client.TokenEndpoint = srv.URL
+	env.client.HostedLoginURL = "https://auth.example.com/login?response_type=code&client_id=" + env.client.ClientID
+	env.client.HostedLogoutURL = "https://auth.example.com/logout"
+	return env
+}
+
+func newTestManager(t *testing.T, env *testEnv, store Store) *Manager {
+	m, err := NewManager(Config{
+		Client: env.client,
+		Key:    []byte("0123456789abcdef0123456789abcdef"),
+		Store:  store,
+	})
+	assert.Nil(t, err)
+	return m
+}
+
+// do serves a request with the cookies and returns the response and the merged cookies
+func do(h http.Handler, r *http.Request, cookies map[string]*http.Cookie) (*http.Response, map[string]*http.Cookie) {
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	merged := map[string]*http.Cookie{}
+	for k, v := range cookies {
+		merged[k] = v
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(merged, c.Name)
+		} else {
+			merged[c.Name] = c
+		}
+	}
+	return w.Result(), merged
+}
+
+// startLogin runs the LoginHandler and returns the state of the redirect and the cookies
+func startLogin(t *testing.T, env *testEnv, m *Manager) (string, map[string]*http.Cookie) {
+	resp, cookies := do(m.LoginHandler(), httptest.NewRequest("GET", "/login?return_to=/dashboard", nil), nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	assert.Nil(t, err)
+	state := loc.Query().Get("state")
+	assert.NotEmpty(t, state)
+	env.nonce = loc.Query().Get("nonce")
+	assert.NotEmpty(t, env.nonce)
+	return state, cookies
+}
+
+func login(t *testing.T, env *testEnv, m *Manager) map[string]*http.Cookie {
+	state, cookies := startLogin(t, env, m)
+
+	resp, cookies := do(m.CallbackHandler(), httptest.NewRequest("GET", "/callback?code=good-code&state="+url.QueryEscape(state), nil), cookies)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/dashboard", resp.Header.Get("Location"))
+	assert.NotContains(t, cookies, m.attemptCookieName())
+	return cookies
+}
+
+func TestLoginFlow(t *testing.T) {
+	for name, store := range map[string]Store{"cookie": nil, "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t)
+			defer env.server.Close()
+			m := newTestManager(t, env, store)
+			if store != nil {
+				m.cfg.AttemptStore = cognito.NewMemoryLoginAttemptStore()
+			}
+			cookies := login(t, env, m)
+
+			var got *Data
+			protected := m.RequireLogin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = FromContext(r.Context())
+			}))
+			resp, _ := do(protected, httptest.NewRequest("GET", "/dashboard", nil), cookies)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "jdoe", got.Claims.Username)
+
+			resp, cookies = do(m.LogoutHandler(), httptest.NewRequest("GET", "/logout", nil), cookies)
+			assert.Equal(t, "https://auth.example.com/logout", resp.Header.Get("Location"))
+			assert.NotContains(t, cookies, DefaultCookieName)
+		})
+	}
+}
+
+func TestCallbackRejectsBadState(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	m := newTestManager(t, env, nil)
+
+	_, cookies := do(m.LoginHandler(), httptest.NewRequest("GET", "/login", nil), nil)
+	resp, _ := do(m.CallbackHandler(), httptest.NewRequest("GET", "/callback?code=good-code&state=forged", nil), cookies)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// No login attempt cookie at all
+	resp, _ = do(m.CallbackHandler(), httptest.NewRequest("GET", "/callback?code=good-code&state=forged", nil), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCallbackRejectsReplayedState(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	m := newTestManager(t, env, nil)
+	m.cfg.AttemptStore = cognito.NewMemoryLoginAttemptStore()
+
+	state, cookies := startLogin(t, env, m)
+	callback := "/callback?code=good-code&state=" + url.QueryEscape(state)
+	resp, _ := do(m.CallbackHandler(), httptest.NewRequest("GET", callback, nil), cookies)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	resp, _ = do(m.CallbackHandler(), httptest.NewRequest("GET", callback, nil), cookies)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCallbackRequiresLoginCookieWithStore(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	m := newTestManager(t, env, NewMemoryStore())
+	m.cfg.AttemptStore = cognito.NewMemoryLoginAttemptStore()
+
+	// A callback forged for another browser carries a valid state but not its cookie
+	state, cookies := startLogin(t, env, m)
+	nonce := env.nonce
+	callback := "/callback?code=good-code&state=" + url.QueryEscape(state)
+	resp, _ := do(m.CallbackHandler(), httptest.NewRequest("GET", callback, nil), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The login cookie of a different attempt does not match either
+	_, otherCookies := startLogin(t, env, m)
+	resp, _ = do(m.CallbackHandler(), httptest.NewRequest("GET", callback, nil), otherCookies)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The attempt was not used up by the rejected callbacks
+	env.nonce = nonce
+	resp, _ = do(m.CallbackHandler(), httptest.NewRequest("GET", callback, nil), cookies)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestCallbackRejectsWrongNonce(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	m := newTestManager(t, env, nil)
+
+	state, cookies := startLogin(t, env, m)
+	env.nonce = "injected"
+	resp, _ := do(m.CallbackHandler(), httptest.NewRequest("GET", "/callback?code=good-code&state="+url.QueryEscape(state), nil), cookies)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestLoadRefreshesExpiredTokens(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	env.expiresIn = 30
+	m := newTestManager(t, env, nil)
+	cookies := login(t, env, m)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	d, err := m.Load(w, r)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, env.refreshes)
+	assert.Equal(t, "refresh-token", d.Token.RefreshToken)
+	assert.True(t, d.Expiry.After(time.Now()))
+	assert.NotEmpty(t, w.Result().Cookies(), "Refreshed session not saved")
+}
+
+func TestRequireLoginWithoutSession(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.server.Close()
+	m := newTestManager(t, env, nil)
+	protected := m.RequireLogin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without session")
+	}))
+
+	r := httptest.NewRequest("GET", "/reports?year=2020", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, _ := do(protected, r, nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/login?return_to=%2Freports%3Fyear%3D2020", resp.Header.Get("Location"))
+
+	resp, _ = do(protected, httptest.NewRequest("GET", "/api/reports", nil), nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	assert.Equal(t, "/dashboard?x=1", safeReturnTo("/dashboard?x=1"))
+	assert.Equal(t, "", safeReturnTo("https://evil.example.com"))
+	assert.Equal(t, "", safeReturnTo("//evil.example.com"))
+	assert.Equal(t, "", safeReturnTo("/\\evil.example.com"))
+}
+
+func TestCodecRejectsTampering(t *testing.T) {
+	c, err := newCodec([]byte("0123456789abcdef"))
+	assert.Nil(t, err)
+
+	value, err := c.encode("a", map[string]string{"k": "v"})
+	assert.Nil(t, err)
+
+	var out map[string]string
+	assert.Nil(t, c.decode("a", value, &out))
+	assert.Equal(t, "v", out["k"])
+	assert.NotNil(t, c.decode("b", value, &out), "Value accepted for another cookie")
+	assert.NotNil(t, c.decode("a", value[:len(value)-2]+"AA", &out), "Tampered value accepted")
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps sessions on the server, the cookie then only holds the session id
+type Store interface {
+	// Get returns the session with the id, or nil if there is none
+	Get(id string) (*Data, error)
+	// Save stores the session under the id until maxAge has passed
+	Save(id string, data *Data, maxAge time.Duration) error
+	// Delete removes the session with the id
+	Delete(id string) error
+}
+
+// MemoryStore is a Store that keeps sessions in memory, for single instance deployments and tests
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data    *Data
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memoryEntry{}}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(id string) (*Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(e.expires) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return e.data, nil
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(id string, data *Data, maxAge time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memoryEntry{data: data, expires: time.Now().Add(maxAge)}
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
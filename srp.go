@@ -0,0 +1,201 @@
+package cognito
+
+// Secure Remote Password (SRP-6a) as implemented by Cognito for USER_SRP_AUTH,
+// DEVICE_SRP_AUTH and device verifiers.
+//
+// Adapted from the AuthenticationHelper of amazon-cognito-identity-js
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// srpN is the 3072 bit group of RFC 5054 used by Cognito
+const srpN = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64" +
+	"ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6B" +
+	"F12FFA06D98A0864D87602733EC86A64521F2B18177B200C" +
+	"BBE117577A615D6C770988C0BAD946E208E24FA074E5AB31" +
+	"43DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+// srpTimeLayout is the TIMESTAMP format Cognito expects, the day is not zero padded
+const srpTimeLayout = "Mon Jan 2 15:04:05 MST 2006"
+
+var (
+	bigN, _ = new(big.Int).SetString(srpN, 16)
+	bigG    = big.NewInt(2)
+	bigK    = hexToBig(hexHash(padHex(bigN) + padHex(bigG)))
+)
+
+// srpClient holds the ephemeral values of a single SRP exchange
+type srpClient struct {
+	// poolName is the part of the pool id after the underscore, or the device group key
+	poolName string
+	a        *big.Int
+	A        *big.Int
+}
+
+// newSRPClient generates a new ephemeral keypair for poolName
+func newSRPClient(poolName string) (*srpClient, error) {
+	for {
+		buf := make([]byte, 128)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		a := new(big.Int).SetBytes(buf)
+		a.Mod(a, bigN)
+		A := new(big.Int).Exp(bigG, a, bigN)
+		if A.Sign() != 0 {
+			return &srpClient{poolName: poolName, a: a, A: A}, nil
+		}
+	}
+}
+
+// srpPoolName returns the pool name used in SRP calculations, e.g. "abc" for "us-east-1_abc"
+func srpPoolName(userPoolID string) string {
+	if i := strings.Index(userPoolID, "_"); i >= 0 {
+		return userPoolID[i+1:]
+	}
+	return userPoolID
+}
+
+// SRPA returns the public value A sent as SRP_A
+func (s *srpClient) SRPA() string {
+	return s.A.Text(16)
+}
+
+// authenticationKey derives the 16 byte HKDF key from the server's challenge parameters
+func (s *srpClient) authenticationKey(username, password, srpB, salt string) ([]byte, error) {
+	B, ok := new(big.Int).SetString(srpB, 16)
+	if !ok || new(big.Int).Mod(B, bigN).Sign() == 0 {
+		return nil, errors.New("invalid SRP_B from server")
+	}
+	u := hexToBig(hexHash(padHex(s.A) + padHex(B)))
+	if u.Sign() == 0 {
+		return nil, errors.New("invalid SRP parameter u")
+	}
+	saltInt, ok := new(big.Int).SetString(salt, 16)
+	if !ok {
+		return nil, errors.New("invalid SALT from server")
+	}
+
+	x := srpX(s.poolName, username, password, saltInt)
+
+	// S = (B - k * g^x) ^ (a + u * x) % N
+	gx := new(big.Int).Exp(bigG, x, bigN)
+	base := new(big.Int).Sub(B, new(big.Int).Mul(bigK, gx))
+	base.Mod(base, bigN)
+	exp := new(big.Int).Add(s.a, new(big.Int).Mul(u, x))
+	S := new(big.Int).Exp(base, exp, bigN)
+
+	return computeHKDF(hexBytes(padHex(S)), hexBytes(padHex(u))), nil
+}
+
+// passwordClaim answers a PASSWORD_VERIFIER or DEVICE_PASSWORD_VERIFIER challenge,
+// returning the TIMESTAMP and PASSWORD_CLAIM_SIGNATURE responses
+func (s *srpClient) passwordClaim(username, password, srpB, salt, secretBlock string, now time.Time) (timestamp, signature string, err error) {
+	key, err := s.authenticationKey(username, password, srpB, salt)
+	if err != nil {
+		return "", "", err
+	}
+	block, err := b64.StdEncoding.DecodeString(secretBlock)
+	if err != nil {
+		return "", "", err
+	}
+
+	timestamp = now.UTC().Format(srpTimeLayout)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s.poolName))
+	mac.Write([]byte(username))
+	mac.Write(block)
+	mac.Write([]byte(timestamp))
+
+	return timestamp, b64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// srpX computes the private key x = H(salt | H(poolName | username | ":" | password))
+func srpX(poolName, username, password string, salt *big.Int) *big.Int {
+	h := sha256.Sum256([]byte(poolName + username + ":" + password))
+	return hexToBig(hexHash(padHex(salt) + hex.EncodeToString(h[:])))
+}
+
+// deviceVerifier generates the salt and password verifier for ConfirmDevice,
+// both base64 encoded
+func deviceVerifier(deviceGroupKey, deviceKey, devicePassword string) (salt, verifier string, err error) {
+	buf := make([]byte, 16)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	saltInt := new(big.Int).SetBytes(buf)
+
+	x := srpX(deviceGroupKey, deviceKey, devicePassword, saltInt)
+	v := new(big.Int).Exp(bigG, x, bigN)
+
+	salt = b64.StdEncoding.EncodeToString(hexBytes(padHex(saltInt)))
+	verifier = b64.StdEncoding.EncodeToString(hexBytes(padHex(v)))
+	return salt, verifier, nil
+}
+
+// computeHKDF is HKDF-SHA256 with the info "Caldera Derived Key", truncated to 16 bytes
+func computeHKDF(ikm, salt []byte) []byte {
+	prk := hmac.New(sha256.New, salt)
+	prk.Write(ikm)
+	mac := hmac.New(sha256.New, prk.Sum(nil))
+	mac.Write([]byte("Caldera Derived Key"))
+	mac.Write([]byte{1})
+	return mac.Sum(nil)[:16]
+}
+
+// padHex returns the hex encoding of i, padded so it is an even length and
+// does not look negative when interpreted as two's complement
+func padHex(i *big.Int) string {
+	h := i.Text(16)
+	if len(h)%2 == 1 {
+		h = "0" + h
+	} else if strings.ContainsRune("89abcdef", rune(h[0])) {
+		h = "00" + h
+	}
+	return h
+}
+
+// hexHash returns the hex encoded SHA256 of the bytes the hex string represents
+func hexHash(h string) string {
+	sum := sha256.Sum256(hexBytes(h))
+	return hex.EncodeToString(sum[:])
+}
+
+func hexBytes(h string) []byte {
+	b, _ := hex.DecodeString(h)
+	return b
+}
+
+func hexToBig(h string) *big.Int {
+	i, _ := new(big.Int).SetString(h, 16)
+	return i
+}
+
+// randomPassword returns a random base64 string used as a device password
+func randomPassword() (string, error) {
+	buf := make([]byte, 40)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return b64.StdEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,126 @@
+package cognito
+
+import (
+	"crypto/rand"
+	b64 "encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// srpServer computes the server side of an SRP exchange for a verifier
+func srpServer(t *testing.T, v *big.Int, A *big.Int) (B *big.Int, key func() []byte) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	assert.Nil(t, err)
+	b := new(big.Int).SetBytes(buf)
+
+	// B = k * v + g^b
+	B = new(big.Int).Add(new(big.Int).Mul(bigK, v), new(big.Int).Exp(bigG, b, bigN))
+	B.Mod(B, bigN)
+
+	return B, func() []byte {
+		// S = (A * v^u) ^ b
+		u := hexToBig(hexHash(padHex(A) + padHex(B)))
+		S := new(big.Int).Mul(A, new(big.Int).Exp(v, u, bigN))
+		S.Exp(S, b, bigN)
+		return computeHKDF(hexBytes(padHex(S)), hexBytes(padHex(u)))
+	}
+}
+
+func TestSRPAuthenticationKey(t *testing.T) {
+	salt := big.NewInt(0xc0ffee)
+	x := srpX("abc", "jdoe", "Passw0rd!", salt)
+	v := new(big.Int).Exp(bigG, x, bigN)
+
+	client, err := newSRPClient(srpPoolName("us-east-1_abc"))
+	assert.Nil(t, err)
+	B, serverKey := srpServer(t, v, client.A)
+
+	key, err := client.authenticationKey("jdoe", "Passw0rd!", B.Text(16), salt.Text(16))
+	assert.Nil(t, err)
+	assert.Equal(t, serverKey(), key)
+
+	key, err = client.authenticationKey("jdoe", "wrong", B.Text(16), salt.Text(16))
+	assert.Nil(t, err)
+	assert.NotEqual(t, serverKey(), key)
+
+	_, err = client.authenticationKey("jdoe", "Passw0rd!", bigN.Text(16), salt.Text(16))
+	assert.NotNil(t, err, "B = N accepted")
+}
+
+func TestDeviceVerifier(t *testing.T) {
+	salt64, verifier64, err := deviceVerifier("group-key", "us-east-1_device", "device-password")
+	assert.Nil(t, err)
+
+	saltBytes, err := b64.StdEncoding.DecodeString(salt64)
+	assert.Nil(t, err)
+	verifierBytes, err := b64.StdEncoding.DecodeString(verifier64)
+	assert.Nil(t, err)
+	salt := new(big.Int).SetBytes(saltBytes)
+	v := new(big.Int).SetBytes(verifierBytes)
+
+	// Cognito uses the verifier for DEVICE_SRP_AUTH with the device group key as pool name
+	client, err := newSRPClient("group-key")
+	assert.Nil(t, err)
+	B, serverKey := srpServer(t, v, client.A)
+
+	key, err := client.authenticationKey("us-east-1_device", "device-password", B.Text(16), salt.Text(16))
+	assert.Nil(t, err)
+	assert.Equal(t, serverKey(), key)
+}
+
+func TestPasswordClaimTimestamp(t *testing.T) {
+	client, err := newSRPClient("abc")
+	assert.Nil(t, err)
+
+	v := new(big.Int).Exp(bigG, srpX("abc", "jdoe", "pw", big.NewInt(1)), bigN)
+	B, _ := srpServer(t, v, client.A)
+	block := b64.StdEncoding.EncodeToString([]byte("secret block"))
+
+	ts, sig, err := client.passwordClaim("jdoe", "pw", B.Text(16), "01", block, time.Date(2020, 1, 5, 3, 4, 5, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, "Sun Jan 5 03:04:05 UTC 2020", ts)
+	assert.NotEmpty(t, sig)
+}
+
+func TestPadHex(t *testing.T) {
+	assert.Equal(t, "0f", padHex(big.NewInt(0xf)))
+	assert.Equal(t, "7f", padHex(big.NewInt(0x7f)))
+	assert.Equal(t, "0080", padHex(big.NewInt(0x80)))
+	assert.Equal(t, "0100", padHex(big.NewInt(0x100)))
+}
+
+func TestSecretHash(t *testing.T) {
+	c := &AppClient{ClientID: "client", ClientSecret: "secret"}
+	assert.Equal(t, "VOSESiBwKvcMZg9OizXU8wg2TGS6RhoITHYBwyX6UjM=", c.SecretHash("jdoe"))
+
+	c.ClientSecret = ""
+	assert.Equal(t, "", c.SecretHash("jdoe"))
+}
+
+func TestFinishAuthKeepsTokensIfDeviceConfirmationFails(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("ConfirmDevice", func(in map[string]interface{}) (interface{}, string) {
+		return nil, "InternalErrorException"
+	})
+
+	c := srv.client()
+	c.DeviceStore = NewMemoryDeviceStore()
+	cip, err := c.NewCIP()
+	assert.Nil(t, err)
+	r, err := c.finishAuth(cip, &AuthResult{
+		Token:     &Token{AccessToken: "access", IDToken: "id"},
+		NewDevice: &DeviceMetadata{DeviceKey: "us-east-1_device", DeviceGroupKey: "group"},
+		loginName: "alice",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "access", r.Token.AccessToken)
+	assert.EqualError(t, r.DeviceError, "InternalErrorException: InternalErrorException")
+	device, err := c.DeviceStore.GetDevice("alice")
+	assert.Nil(t, err)
+	assert.Nil(t, device)
+}
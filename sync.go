@@ -0,0 +1,181 @@
+package cognito
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// MembershipAction is a change to a user's group membership
+type MembershipAction string
+
+// Membership actions computed by SyncGroupMembership
+const (
+	MembershipAdd    MembershipAction = "add"
+	MembershipRemove MembershipAction = "remove"
+)
+
+// MembershipChange adds a user to or removes a user from a group
+type MembershipChange struct {
+	Group    string           `json:"group"`
+	Username string           `json:"username"`
+	Action   MembershipAction `json:"action"`
+}
+
+// MembershipResult is the outcome of applying a MembershipChange
+type MembershipResult struct {
+	MembershipChange
+	Applied bool  `json:"applied"`
+	Retries int   `json:"retries"`
+	Err     error `json:"-"`
+}
+
+// GroupSyncOptions controls SyncGroupMembership
+type GroupSyncOptions struct {
+	// DryRun only computes the changes without applying them
+	DryRun bool
+	// Concurrency is the number of changes applied at once, defaults to DefaultBatchConcurrency
+	Concurrency int
+	// MaxRetries is the number of retries of a throttled change, DefaultMaxRetries if 0, none if negative
+	MaxRetries int
+	// RetryBaseDelay is the initial backoff of a throttled change, defaults to DefaultRetryBaseDelay
+	RetryBaseDelay time.Duration
+}
+
+// GroupSyncReport lists the changes SyncGroupMembership made, or would make in a dry run
+type GroupSyncReport struct {
+	DryRun  bool               `json:"dryRun"`
+	Results []MembershipResult `json:"results"`
+}
+
+// Failed returns the results of changes that could not be applied
+func (r *GroupSyncReport) Failed() []MembershipResult {
+	var failed []MembershipResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// SyncGroupMembership reconciles the members of the groups in desired with the live user pool.
+// Users missing from a group are added and users not listed are removed, groups that are not
+// keys of desired are left untouched, an empty list removes every member of the group.
+// Usernames are compared with the Username Cognito returns for the group members.
+//
+// An error is returned if the current members cannot be listed, failures of individual
+// changes are reported in the results.
+// Requires a AWS session with developer credentials
+func (c *AppClient) SyncGroupMembership(desired map[string][]string, opts *GroupSyncOptions) (*GroupSyncReport, error) {
+	if opts == nil {
+		opts = &GroupSyncOptions{}
+	}
+
+	current := make(map[string][]string, len(desired))
+	for group := range desired {
+		users, err := c.ListUsersInGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			current[group] = append(current[group], aws.StringValue(u.Username))
+		}
+	}
+
+	changes := diffGroupMembership(desired, current)
+	if opts.DryRun {
+		report := &GroupSyncReport{DryRun: true}
+		for _, change := range changes {
+			report.Results = append(report.Results, MembershipResult{MembershipChange: change})
+		}
+		return report, nil
+	}
+
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+	ctx := aws.BackgroundContext()
+	return applyMembershipChanges(changes, opts, func(change MembershipChange) (retries int, err error) {
+		retry := c.withRetries(opts.MaxRetries, opts.RetryBaseDelay, &retries)
+		if change.Action == MembershipAdd {
+			_, err = cip.AdminAddUserToGroupWithContext(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+				Username:   aws.String(change.Username),
+				GroupName:  aws.String(change.Group),
+				UserPoolId: &c.UserPoolID,
+			}, retry)
+		} else {
+			_, err = cip.AdminRemoveUserFromGroupWithContext(ctx, &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+				Username:   aws.String(change.Username),
+				GroupName:  aws.String(change.Group),
+				UserPoolId: &c.UserPoolID,
+			}, retry)
+		}
+		return retries, err
+	}), nil
+}
+
+// diffGroupMembership returns the changes that turn current into desired,
+// sorted by group, action and username
+func diffGroupMembership(desired, current map[string][]string) []MembershipChange {
+	var changes []MembershipChange
+	for group, want := range desired {
+		have := make(map[string]bool, len(current[group]))
+		for _, username := range current[group] {
+			have[username] = true
+		}
+		keep := make(map[string]bool, len(want))
+		for _, username := range want {
+			if keep[username] {
+				continue
+			}
+			keep[username] = true
+			if !have[username] {
+				changes = append(changes, MembershipChange{Group: group, Username: username, Action: MembershipAdd})
+			}
+		}
+		for username := range have {
+			if !keep[username] {
+				changes = append(changes, MembershipChange{Group: group, Username: username, Action: MembershipRemove})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+		return a.Username < b.Username
+	})
+	return changes
+}
+
+// applyMembershipChanges applies the changes with bounded concurrency, apply returns
+// the retries the change took
+func applyMembershipChanges(changes []MembershipChange, opts *GroupSyncOptions, apply func(MembershipChange) (int, error)) *GroupSyncReport {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	report := &GroupSyncReport{Results: make([]MembershipResult, len(changes))}
+	forEachConcurrently(len(changes), concurrency, func(i int) {
+		change := changes[i]
+		retries, err := apply(change)
+		report.Results[i] = MembershipResult{
+			MembershipChange: change,
+			Applied:          err == nil,
+			Retries:          retries,
+			Err:              err,
+		}
+	})
+
+	return report
+}
@@ -0,0 +1,98 @@
+package cognito
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffGroupMembership(t *testing.T) {
+	desired := map[string][]string{
+		"admins": {"alice", "bob", "bob"},
+		"users":  {},
+	}
+	current := map[string][]string{
+		"admins":  {"bob", "carol"},
+		"users":   {"dave"},
+		"ignored": {"erin"},
+	}
+
+	assert.Equal(t, []MembershipChange{
+		{Group: "admins", Username: "alice", Action: MembershipAdd},
+		{Group: "admins", Username: "carol", Action: MembershipRemove},
+		{Group: "users", Username: "dave", Action: MembershipRemove},
+	}, diffGroupMembership(desired, current))
+}
+
+func TestApplyMembershipChanges(t *testing.T) {
+	changes := []MembershipChange{
+		{Group: "admins", Username: "alice", Action: MembershipAdd},
+		{Group: "admins", Username: "bob", Action: MembershipAdd},
+		{Group: "admins", Username: "carol", Action: MembershipRemove},
+	}
+
+	report := applyMembershipChanges(changes, &GroupSyncOptions{Concurrency: 2}, func(change MembershipChange) (int, error) {
+		switch change.Username {
+		case "alice":
+			return 2, nil
+		case "bob":
+			return 0, errors.New("user not found")
+		}
+		return 0, nil
+	})
+
+	assert.Len(t, report.Results, 3)
+	assert.True(t, report.Results[0].Applied)
+	assert.Equal(t, 2, report.Results[0].Retries)
+	assert.False(t, report.Results[1].Applied)
+	assert.Equal(t, 0, report.Results[1].Retries)
+	assert.True(t, report.Results[2].Applied)
+	assert.Len(t, report.Failed(), 1)
+	assert.Equal(t, "bob", report.Failed()[0].Username)
+}
+
+func TestSyncGroupMembershipRetries(t *testing.T) {
+	srv := newCIPServer(t)
+	defer srv.Close()
+	srv.handle("ListUsersInGroup", pages("NextToken", "NextToken", "Users", []map[string]interface{}{{"Username": "carol"}}))
+	srv.handle("AdminRemoveUserFromGroup", func(in map[string]interface{}) (interface{}, string) { return nil, "" })
+	adds := 0
+	srv.handle("AdminAddUserToGroup", func(in map[string]interface{}) (interface{}, string) {
+		// throttled twice, then succeeds
+		if adds++; adds <= 2 {
+			return nil, "TooManyRequestsException"
+		}
+		return nil, ""
+	})
+
+	var mu sync.Mutex
+	var hookRetries []int
+	c := srv.client()
+	c.Hooks = &Hooks{OnRetry: func(operation string, retry int, delay time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		hookRetries = append(hookRetries, retry)
+	}}
+	desired := map[string][]string{"admins": {"alice"}}
+	report, err := c.SyncGroupMembership(desired, &GroupSyncOptions{RetryBaseDelay: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Len(t, report.Results, 2)
+	assert.True(t, report.Results[0].Applied)
+	assert.Equal(t, 2, report.Results[0].Retries)
+	assert.True(t, report.Results[1].Applied)
+	// Throttled changes are retried once per attempt, not by two retry layers
+	assert.Equal(t, 3, adds)
+	assert.Equal(t, []int{1, 2}, hookRetries)
+
+	// A negative MaxRetries turns off retries
+	adds = 0
+	report, err = c.SyncGroupMembership(desired, &GroupSyncOptions{MaxRetries: -1})
+	assert.Nil(t, err)
+	assert.False(t, report.Results[0].Applied)
+	assert.True(t, IsThrottlingError(report.Results[0].Err))
+	assert.Equal(t, 0, report.Results[0].Retries)
+	assert.Equal(t, 1, adds)
+}
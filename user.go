@@ -0,0 +1,368 @@
+package cognito
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+)
+
+// CustomAttributePrefix is the prefix Cognito requires for custom attributes
+const CustomAttributePrefix = "custom:"
+
+// standardAttributes are the attributes Cognito defines for every user pool,
+// any other attribute name is prefixed with "custom:"
+var standardAttributes = map[string]bool{
+	"address":               true,
+	"birthdate":             true,
+	"email":                 true,
+	"email_verified":        true,
+	"family_name":           true,
+	"gender":                true,
+	"given_name":            true,
+	"locale":                true,
+	"middle_name":           true,
+	"name":                  true,
+	"nickname":              true,
+	"phone_number":          true,
+	"phone_number_verified": true,
+	"picture":               true,
+	"preferred_username":    true,
+	"profile":               true,
+	"sub":                   true,
+	"updated_at":            true,
+	"website":               true,
+	"zoneinfo":              true,
+}
+
+// UserStatus is the account status of a Cognito user
+type UserStatus string
+
+// Account statuses returned by Cognito
+const (
+	UserStatusUnconfirmed         UserStatus = "UNCONFIRMED"
+	UserStatusConfirmed           UserStatus = "CONFIRMED"
+	UserStatusArchived            UserStatus = "ARCHIVED"
+	UserStatusCompromised         UserStatus = "COMPROMISED"
+	UserStatusUnknown             UserStatus = "UNKNOWN"
+	UserStatusResetRequired       UserStatus = "RESET_REQUIRED"
+	UserStatusForceChangePassword UserStatus = "FORCE_CHANGE_PASSWORD"
+	UserStatusExternalProvider    UserStatus = "EXTERNAL_PROVIDER"
+)
+
+// CanSignIn reports whether a user with this status can authenticate with a password
+// without further action, i.e. without confirming, resetting or changing the password
+func (s UserStatus) CanSignIn() bool {
+	return s == UserStatusConfirmed
+}
+
+// User is a Cognito user with its attributes flattened into a map
+type User struct {
+	Username            string            `json:"username"`
+	Status              UserStatus        `json:"status,omitempty"`
+	Enabled             bool              `json:"enabled"`
+	MFAOptions          []MFAOption       `json:"mfaOptions,omitempty"`
+	PreferredMFASetting string            `json:"preferredMfaSetting,omitempty"`
+	MFASettings         []string          `json:"mfaSettings,omitempty"`
+	Attributes          map[string]string `json:"attributes"`
+	CreatedAt           time.Time         `json:"createdAt,omitempty"`
+	ModifiedAt          time.Time         `json:"modifiedAt,omitempty"`
+}
+
+// MFAOption is a legacy SMS MFA setting of a user
+type MFAOption struct {
+	DeliveryMedium string `json:"deliveryMedium"`
+	AttributeName  string `json:"attributeName"`
+}
+
+// Attribute returns the value of the named attribute, custom attributes
+// may be given with or without the "custom:" prefix
+func (u *User) Attribute(name string) string {
+	if v, ok := u.Attributes[name]; ok {
+		return v
+	}
+	return u.Attributes[AttributeName(name)]
+}
+
+// Sub returns the immutable cognito id of the user
+func (u *User) Sub() string {
+	return u.Attributes["sub"]
+}
+
+// AttributeName returns the name Cognito expects for an attribute, adding the
+// "custom:" prefix to anything that is not a standard or developer attribute
+func AttributeName(name string) string {
+	if standardAttributes[name] || strings.HasPrefix(name, CustomAttributePrefix) || strings.HasPrefix(name, "dev:") {
+		return name
+	}
+	return CustomAttributePrefix + name
+}
+
+// attributeTypes converts an attribute map to Cognito attribute types, sorted by name
+func attributeTypes(attributes map[string]string) []*cognitoidentityprovider.AttributeType {
+	return nameValues(attributes, AttributeName)
+}
+
+// nameValues converts a map to Cognito attribute types sorted by key, naming each
+// with name, or with the key as is if name is nil
+func nameValues(m map[string]string, name func(string) string) []*cognitoidentityprovider.AttributeType {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	at := make([]*cognitoidentityprovider.AttributeType, 0, len(keys))
+	for _, key := range keys {
+		n := key
+		if name != nil {
+			n = name(key)
+		}
+		at = append(at, &cognitoidentityprovider.AttributeType{
+			Name:  aws.String(n),
+			Value: aws.String(m[key]),
+		})
+	}
+	return at
+}
+
+// attributeMap flattens Cognito attribute types into a map
+func attributeMap(at []*cognitoidentityprovider.AttributeType) map[string]string {
+	m := make(map[string]string, len(at))
+	for _, a := range at {
+		m[aws.StringValue(a.Name)] = aws.StringValue(a.Value)
+	}
+	return m
+}
+
+func mfaOptions(opts []*cognitoidentityprovider.MFAOptionType) []MFAOption {
+	var mo []MFAOption
+	for _, o := range opts {
+		mo = append(mo, MFAOption{
+			DeliveryMedium: aws.StringValue(o.DeliveryMedium),
+			AttributeName:  aws.StringValue(o.AttributeName),
+		})
+	}
+	return mo
+}
+
+// NewUser converts a Cognito UserType, as returned by ListUsers, into a User
+func NewUser(ut *cognitoidentityprovider.UserType) *User {
+	return &User{
+		Username:   aws.StringValue(ut.Username),
+		Status:     UserStatus(aws.StringValue(ut.UserStatus)),
+		Enabled:    aws.BoolValue(ut.Enabled),
+		MFAOptions: mfaOptions(ut.MFAOptions),
+		Attributes: attributeMap(ut.Attributes),
+		CreatedAt:  aws.TimeValue(ut.UserCreateDate),
+		ModifiedAt: aws.TimeValue(ut.UserLastModifiedDate),
+	}
+}
+
+// AdminGetUser gets a user and all of its attributes by username
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminGetUser(username string) (*User, error) {
+	input := &cognitoidentityprovider.AdminGetUserInput{
+		Username:   aws.String(username),
+		UserPoolId: &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.AdminGetUser(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &User{
+		Username:            aws.StringValue(out.Username),
+		Status:              UserStatus(aws.StringValue(out.UserStatus)),
+		Enabled:             aws.BoolValue(out.Enabled),
+		MFAOptions:          mfaOptions(out.MFAOptions),
+		PreferredMFASetting: aws.StringValue(out.PreferredMfaSetting),
+		MFASettings:         aws.StringValueSlice(out.UserMFASettingList),
+		Attributes:          attributeMap(out.UserAttributes),
+		CreatedAt:           aws.TimeValue(out.UserCreateDate),
+		ModifiedAt:          aws.TimeValue(out.UserLastModifiedDate),
+	}, nil
+}
+
+// AdminUpdateUserAttributes sets the given attributes on a user, attributes that are
+// not standard are prefixed with "custom:"
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminUpdateUserAttributes(username string, attributes map[string]string) error {
+	input := &cognitoidentityprovider.AdminUpdateUserAttributesInput{
+		Username:       aws.String(username),
+		UserAttributes: attributeTypes(attributes),
+		UserPoolId:     &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.AdminUpdateUserAttributes(input)
+	return err
+}
+
+// AdminDeleteUserAttributes removes the named attributes from a user
+// Requires a AWS session with developer credentials
+func (c *AppClient) AdminDeleteUserAttributes(username string, names ...string) error {
+	input := &cognitoidentityprovider.AdminDeleteUserAttributesInput{
+		Username:           aws.String(username),
+		UserAttributeNames: attributeNames(names),
+		UserPoolId:         &c.UserPoolID,
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.AdminDeleteUserAttributes(input)
+	return err
+}
+
+// GetUser gets the user the access token belongs to,
+// Status and Enabled are not returned by the self-service API
+func (c *AppClient) GetUser(accessToken string) (*User, error) {
+	input := &cognitoidentityprovider.GetUserInput{
+		AccessToken: aws.String(accessToken),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.GetUser(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &User{
+		Username:            aws.StringValue(out.Username),
+		MFAOptions:          mfaOptions(out.MFAOptions),
+		PreferredMFASetting: aws.StringValue(out.PreferredMfaSetting),
+		MFASettings:         aws.StringValueSlice(out.UserMFASettingList),
+		Attributes:          attributeMap(out.UserAttributes),
+	}, nil
+}
+
+// UpdateUserAttributes sets attributes on the user the access token belongs to.
+// Changing email or phone_number sends a verification code, the delivery details are returned.
+func (c *AppClient) UpdateUserAttributes(accessToken string, attributes map[string]string) ([]*cognitoidentityprovider.CodeDeliveryDetailsType, error) {
+	input := &cognitoidentityprovider.UpdateUserAttributesInput{
+		AccessToken:    aws.String(accessToken),
+		UserAttributes: attributeTypes(attributes),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cip.UpdateUserAttributes(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.CodeDeliveryDetailsList, nil
+}
+
+// VerifyUserAttribute verifies an attribute, e.g. email, of the user the access
+// token belongs to with the code sent by UpdateUserAttributes
+func (c *AppClient) VerifyUserAttribute(accessToken, name, code string) error {
+	input := &cognitoidentityprovider.VerifyUserAttributeInput{
+		AccessToken:   aws.String(accessToken),
+		AttributeName: aws.String(AttributeName(name)),
+		Code:          aws.String(code),
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return err
+	}
+
+	_, err = cip.VerifyUserAttribute(input)
+	return err
+}
+
+func attributeNames(names []string) []*string {
+	an := make([]*string, 0, len(names))
+	for _, name := range names {
+		an = append(an, aws.String(AttributeName(name)))
+	}
+	return an
+}
+
+// ListUsersOptions filter the users ListUsersFiltered returns
+type ListUsersOptions struct {
+	// Filter is a Cognito filter expression, see UserFilter
+	Filter string
+	// Attributes limits the returned attributes, all attributes if empty
+	Attributes []string
+	// Limit stops listing after this many users, all users if 0
+	Limit int
+}
+
+// UserFilter returns a ListUsers filter expression that matches users whose attribute equals
+// value, or starts with value if prefix is set. Only standard attributes and
+// "cognito:user_status" and "status" can be filtered.
+func UserFilter(attribute, value string, prefix bool) string {
+	op := "="
+	if prefix {
+		op = "^="
+	}
+	return fmt.Sprintf("%s %s %s", attribute, op, strconv.Quote(value))
+}
+
+// ListUsersFiltered lists the users matching the options, following all pages
+// Requires a AWS session with developer credentials
+func (c *AppClient) ListUsersFiltered(opts *ListUsersOptions) ([]*User, error) {
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: &c.UserPoolID,
+	}
+	if opts.Filter != "" {
+		input.Filter = aws.String(opts.Filter)
+	}
+	if len(opts.Attributes) > 0 {
+		input.AttributesToGet = attributeNames(opts.Attributes)
+	}
+
+	// Create the CognitoIdentityProvider
+	cip, err := c.NewCIP()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*User
+	err = cip.ListUsersPages(input, func(out *cognitoidentityprovider.ListUsersOutput, last bool) bool {
+		for _, ut := range out.Users {
+			if opts.Limit > 0 && len(users) >= opts.Limit {
+				return false
+			}
+			users = append(users, NewUser(ut))
+		}
+		return opts.Limit == 0 || len(users) < opts.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,54 @@
+package cognito
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeName(t *testing.T) {
+	tests := map[string]string{
+		"email":         "email",
+		"phone_number":  "phone_number",
+		"tenant":        "custom:tenant",
+		"custom:tenant": "custom:tenant",
+		"dev:internal":  "dev:internal",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, AttributeName(in), in)
+	}
+}
+
+func TestAttributeTypes(t *testing.T) {
+	at := attributeTypes(map[string]string{"tenant": "acme", "email": "a@example.com"})
+	assert.Len(t, at, 2)
+	assert.Equal(t, "email", aws.StringValue(at[0].Name))
+	assert.Equal(t, "custom:tenant", aws.StringValue(at[1].Name))
+	assert.Equal(t, "acme", aws.StringValue(at[1].Value))
+}
+
+func TestNewUser(t *testing.T) {
+	u := NewUser(&cognitoidentityprovider.UserType{
+		Username:   aws.String("jdoe"),
+		UserStatus: aws.String("CONFIRMED"),
+		Enabled:    aws.Bool(true),
+		Attributes: []*cognitoidentityprovider.AttributeType{
+			{Name: aws.String("sub"), Value: aws.String("1234")},
+			{Name: aws.String("custom:tenant"), Value: aws.String("acme")},
+		},
+	})
+	assert.Equal(t, "jdoe", u.Username)
+	assert.Equal(t, UserStatusConfirmed, u.Status)
+	assert.True(t, u.Status.CanSignIn())
+	assert.True(t, u.Enabled)
+	assert.Equal(t, "1234", u.Sub())
+	assert.Equal(t, "acme", u.Attribute("tenant"))
+	assert.Equal(t, "acme", u.Attribute("custom:tenant"))
+}
+
+func TestUserFilter(t *testing.T) {
+	assert.Equal(t, `email = "jdoe@example.com"`, UserFilter("email", "jdoe@example.com", false))
+	assert.Equal(t, `name ^= "Jo \"JD\""`, UserFilter("name", `Jo "JD"`, true))
+}
@@ -0,0 +1,250 @@
+package cognito
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// MinJWKSRefreshInterval limits how often a pool's keys are fetched again when a token
+// carries an unknown kid, so forged tokens cannot make the verifier hammer the JWKS endpoint
+const MinJWKSRefreshInterval = time.Minute
+
+// ErrUnknownIssuer is returned for tokens of a pool that is not registered
+var ErrUnknownIssuer = errors.New("token issuer is not a registered user pool")
+
+// PoolConfig registers a user pool with a Verifier
+type PoolConfig struct {
+	// Tenant is reported for tokens of this pool, defaults to the pool id
+	Tenant     string `json:"tenant"`
+	Region     string `json:"region"`
+	UserPoolID string `json:"poolId"`
+	// ClientIDs are the app clients whose tokens are accepted, all clients if empty
+	ClientIDs []string `json:"clientIds"`
+	// JWKSURL overrides https://cognito-idp.<region>.amazonaws.com/<pool_id>/.well-known/jwks.json
+	JWKSURL string `json:"jwksUrl,omitempty"`
+	// Keys are used instead of fetching the JWKS, e.g. for offline verification
+	Keys *jwk.Set `json:"-"`
+}
+
+// Issuer returns the iss claim of the pool's tokens
+func (p PoolConfig) Issuer() string {
+	return issuerURL(p.Region, p.UserPoolID)
+}
+
+func (p PoolConfig) jwksURL() string {
+	if p.JWKSURL != "" {
+		return p.JWKSURL
+	}
+	return p.Issuer() + "/.well-known/jwks.json"
+}
+
+func (p PoolConfig) allowsClient(clientID string) bool {
+	if len(p.ClientIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ClientIDs {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifiedToken is a token a Verifier accepted
+type VerifiedToken struct {
+	// Tenant of the pool that issued the token
+	Tenant string
+	Pool   PoolConfig
+	// TokenUse is "id" or "access"
+	TokenUse string
+	// ClientID is the app client the token was issued to
+	ClientID string
+	Token    *jwt.Token
+	Claims   jwt.MapClaims
+}
+
+// IDClaims returns the claims of a verified ID token
+func (vt *VerifiedToken) IDClaims() (*IDClaims, error) {
+	return NewIDClaims(vt.Token)
+}
+
+// Verifier verifies tokens from a set of user pools, e.g. one pool per tenant. The pool is
+// selected by the iss claim, and only registered pools are accepted. The keys of a pool are
+// fetched on first use and cached.
+type Verifier struct {
+	mu    sync.RWMutex
+	pools map[string]*verifierPool
+}
+
+// verifierPool holds the cached keys of a registered pool
+type verifierPool struct {
+	PoolConfig
+
+	mu   sync.Mutex
+	keys *jwk.Set
+	// fetched is the time of the last fetch, failed or not, fetchErr its error
+	fetched  time.Time
+	fetchErr error
+}
+
+// NewVerifier returns a Verifier for the pools
+func NewVerifier(pools ...PoolConfig) (*Verifier, error) {
+	v := &Verifier{pools: map[string]*verifierPool{}}
+	for _, p := range pools {
+		if err := v.Register(p); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Register adds a pool, replacing a pool with the same issuer
+func (v *Verifier) Register(p PoolConfig) error {
+	if p.Region == "" || p.UserPoolID == "" {
+		return errors.New("pool config needs region and pool id")
+	}
+	if p.Tenant == "" {
+		p.Tenant = p.UserPoolID
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pools[p.Issuer()] = &verifierPool{PoolConfig: p, keys: p.Keys}
+	return nil
+}
+
+// Unregister removes the pool of the issuer
+func (v *Verifier) Unregister(issuer string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.pools, issuer)
+}
+
+// Pools returns the registered pools
+func (v *Verifier) Pools() []PoolConfig {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	pools := make([]PoolConfig, 0, len(v.pools))
+	for _, p := range v.pools {
+		pools = append(pools, p.PoolConfig)
+	}
+	return pools
+}
+
+func (v *Verifier) pool(issuer string) *verifierPool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.pools[issuer]
+}
+
+// Verify verifies the signature, expiry, issuer and client of an ID or access token
+func (v *Verifier) Verify(t string) (*VerifiedToken, error) {
+	var pool *verifierPool
+	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected alg %v, Cognito signs with RS256", token.Header["alg"])
+		}
+		mc, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.New("token claims are not map claims")
+		}
+		// The issuer is not verified yet, it only selects the keys to verify with
+		if pool = v.pool(claimString(mc, "iss")); pool == nil {
+			return nil, ErrUnknownIssuer
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no `kid` header")
+		}
+		return v.publicKey(pool, kid)
+	})
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner != nil {
+			err = ve.Inner
+		}
+		return nil, err
+	}
+
+	mc := token.Claims.(jwt.MapClaims)
+	vt := &VerifiedToken{
+		Tenant:   pool.Tenant,
+		Pool:     pool.PoolConfig,
+		TokenUse: claimString(mc, "token_use"),
+		Token:    token,
+		Claims:   mc,
+	}
+	switch vt.TokenUse {
+	case "id":
+		vt.ClientID = claimString(mc, "aud")
+	case "access":
+		vt.ClientID = claimString(mc, "client_id")
+	default:
+		return nil, fmt.Errorf("unexpected token_use %q", vt.TokenUse)
+	}
+	if !pool.allowsClient(vt.ClientID) {
+		return nil, fmt.Errorf("client %q is not allowed for tenant %s", vt.ClientID, pool.Tenant)
+	}
+	return vt, nil
+}
+
+// VerifyIDToken verifies an ID token and returns its claims and the tenant it belongs to
+func (v *Verifier) VerifyIDToken(idToken string) (*IDClaims, string, error) {
+	vt, err := v.Verify(idToken)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := vt.IDClaims()
+	if err != nil {
+		return nil, "", err
+	}
+	return claims, vt.Tenant, nil
+}
+
+// publicKey returns the key with the kid, fetching the pool's keys if they are not cached
+// or do not have the kid
+func (v *Verifier) publicKey(pool *verifierPool, kid string) (*rsa.PublicKey, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var keys []jwk.Key
+	if pool.keys != nil {
+		keys = pool.keys.LookupKeyID(kid)
+	}
+	if len(keys) == 0 && pool.Keys == nil && time.Since(pool.fetched) > MinJWKSRefreshInterval {
+		// A failed fetch counts too, so an unreachable endpoint is not hammered
+		pool.fetched = time.Now()
+		set, err := jwk.Fetch(pool.jwksURL())
+		if err != nil {
+			pool.fetchErr = fmt.Errorf("could not fetch keys of %s: %v", pool.Tenant, err)
+			return nil, pool.fetchErr
+		}
+		pool.keys, pool.fetchErr = set, nil
+		keys = set.LookupKeyID(kid)
+	}
+	if len(keys) == 0 && pool.fetchErr != nil {
+		return nil, pool.fetchErr
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("could not find matching `kid` in well known tokens")
+	}
+
+	key, err := keys[0].Materialize()
+	if err != nil {
+		return nil, err
+	}
+	rsaPublicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("well known key is not an RSA public key")
+	}
+	return rsaPublicKey, nil
+}
+
+// issuerURL returns https://cognito-idp.<region>.amazonaws.com/<pool_id>
+func issuerURL(region, poolID string) string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, poolID)
+}
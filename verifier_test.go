@@ -0,0 +1,115 @@
+package cognito_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/joescharf/cognito"
+	"github.com/joescharf/cognito/cognitotest"
+)
+
+func TestVerifierSelectsPoolByIssuer(t *testing.T) {
+	acme := cognitotest.MustNewIssuer("us-east-1", "us-east-1_Acme", "acme-client")
+	globex := cognitotest.MustNewIssuer("eu-west-1", "eu-west-1_Globex", "globex-client")
+	stranger := cognitotest.MustNewIssuer("us-east-1", "us-east-1_Other", "acme-client")
+
+	fetches := 0
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(globex.KeySet())
+	}))
+	defer jwks.Close()
+
+	v, err := cognito.NewVerifier(
+		cognito.PoolConfig{Tenant: "acme", Region: "us-east-1", UserPoolID: "us-east-1_Acme", ClientIDs: []string{"acme-client"}, Keys: acme.KeySet()},
+		cognito.PoolConfig{Tenant: "globex", Region: "eu-west-1", UserPoolID: "eu-west-1_Globex", JWKSURL: jwks.URL},
+	)
+	assert.Nil(t, err)
+
+	idToken, _ := acme.MintIDToken(cognitotest.TokenOptions{Username: "jdoe"})
+	claims, tenant, err := v.VerifyIDToken(idToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", tenant)
+	assert.Equal(t, "jdoe", claims.Username)
+
+	// Keys of a pool are fetched once
+	for i := 0; i < 2; i++ {
+		accessToken, _ := globex.MintAccessToken(cognitotest.TokenOptions{})
+		vt, err := v.Verify(accessToken)
+		assert.Nil(t, err)
+		assert.Equal(t, "globex", vt.Tenant)
+		assert.Equal(t, "access", vt.TokenUse)
+		assert.Equal(t, "globex-client", vt.ClientID)
+	}
+	assert.Equal(t, 1, fetches)
+
+	// Unregistered pools are rejected
+	idToken, _ = stranger.MintIDToken(cognitotest.TokenOptions{})
+	_, err = v.Verify(idToken)
+	assert.Equal(t, cognito.ErrUnknownIssuer, err)
+
+	// A registered issuer does not make another pool's keys acceptable
+	forged, _ := stranger.Mint(map[string]interface{}{"iss": acme.IssuerURL(), "token_use": "id", "aud": "acme-client"}, acme.KeyID)
+	_, err = v.Verify(forged)
+	assert.NotNil(t, err)
+}
+
+func TestVerifierRejectsOtherClients(t *testing.T) {
+	acme := cognitotest.MustNewIssuer("us-east-1", "us-east-1_Acme", "other-client")
+	v, err := cognito.NewVerifier(cognito.PoolConfig{Region: "us-east-1", UserPoolID: "us-east-1_Acme", ClientIDs: []string{"acme-client"}, Keys: acme.KeySet()})
+	assert.Nil(t, err)
+
+	idToken, _ := acme.MintIDToken(cognitotest.TokenOptions{})
+	_, err = v.Verify(idToken)
+	assert.NotNil(t, err)
+
+	_, err = cognito.NewVerifier(cognito.PoolConfig{UserPoolID: "us-east-1_Acme"})
+	assert.NotNil(t, err, "Pool without region accepted")
+}
+
+func TestVerifierRequiresRS256(t *testing.T) {
+	acme := cognitotest.MustNewIssuer("us-east-1", "us-east-1_Acme", "acme-client")
+	v, err := cognito.NewVerifier(cognito.PoolConfig{Region: "us-east-1", UserPoolID: "us-east-1_Acme", Keys: acme.KeySet()})
+	assert.Nil(t, err)
+
+	// A valid token with its header changed to another RSA algorithm
+	idToken, _ := acme.MintIDToken(cognitotest.TokenOptions{})
+	header, _ := json.Marshal(map[string]string{"alg": "RS384", "kid": acme.KeyID, "typ": "JWT"})
+	parts := strings.SplitN(idToken, ".", 2)
+	_, err = v.Verify(base64.RawURLEncoding.EncodeToString(header) + "." + parts[1])
+	assert.EqualError(t, err, "unexpected alg RS384, Cognito signs with RS256")
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": acme.IssuerURL()}).SignedString([]byte("secret"))
+	assert.Nil(t, err)
+	_, err = v.Verify(forged)
+	assert.EqualError(t, err, "unexpected alg HS256, Cognito signs with RS256")
+}
+
+func TestVerifierLimitsFailedFetches(t *testing.T) {
+	acme := cognitotest.MustNewIssuer("us-east-1", "us-east-1_Acme", "acme-client")
+	fetches := 0
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer jwks.Close()
+
+	v, err := cognito.NewVerifier(cognito.PoolConfig{Region: "us-east-1", UserPoolID: "us-east-1_Acme", JWKSURL: jwks.URL})
+	assert.Nil(t, err)
+
+	// The failed fetch is not retried within MinJWKSRefreshInterval
+	idToken, _ := acme.MintIDToken(cognitotest.TokenOptions{})
+	for i := 0; i < 3; i++ {
+		_, err = v.Verify(idToken)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "could not fetch keys")
+	}
+	assert.Equal(t, 1, fetches)
+}